@@ -0,0 +1,72 @@
+package iso8583
+
+import (
+	"fmt"
+	"strconv"
+)
+
+// isSignedAmount reports whether a field is a signed amount (ContentType x+n),
+// a C (credit) or D (debit) followed by the digits of the amount
+func isSignedAmount(fieldDescription FieldDescription) bool {
+	return fieldDescription.ContentType == "x+n"
+}
+
+// formatAmount renders an amount as a sign followed by digits digits,
+// digits of 0 leaves the amount unpadded
+func formatAmount(amount int64, digits int) string {
+	sign := "C"
+	if amount < 0 {
+		sign = "D"
+		amount = -amount
+	}
+	return sign + leftPad(strconv.FormatInt(amount, 10), digits, "0")
+}
+
+// parseAmount turns a signed amount back into an integer
+func parseAmount(data string) (int64, error) {
+	if len(data) < 2 {
+		return 0, fmt.Errorf("%q is not a signed amount", data)
+	}
+	amount, err := strconv.ParseUint(data[1:], 10, 63)
+	if err != nil {
+		return 0, fmt.Errorf("%q is not a signed amount", data)
+	}
+	switch data[0] {
+	case 'C':
+		return int64(amount), nil
+	case 'D':
+		return -int64(amount), nil
+	}
+	return 0, fmt.Errorf("%q is not a signed amount, expected C or D found %q", data, data[0])
+}
+
+// GetAmount returns the value of a signed amount field as an integer,
+// credits are positive and debits negative
+func (iso *IsoStruct) GetAmount(field int64) (int64, error) {
+	if !isSignedAmount(iso.Spec.fields[int(field)]) {
+		return 0, fmt.Errorf("field %d is not a signed amount", field)
+	}
+	data, ok := iso.Elements.elements[field]
+	if !ok {
+		return 0, fmt.Errorf("field %d is not present", field)
+	}
+	amount, err := parseAmount(data)
+	if err != nil {
+		return 0, fmt.Errorf("field %d: %s", field, err.Error())
+	}
+	return amount, nil
+}
+
+// AddAmount adds a signed amount field, positive amounts are sent as
+// credits and negative ones as debits
+func (iso *IsoStruct) AddAmount(field int64, amount int64) error {
+	fieldDescription := iso.Spec.fields[int(field)]
+	if !isSignedAmount(fieldDescription) {
+		return fmt.Errorf("field %d is not a signed amount", field)
+	}
+	var digits int
+	if fieldDescription.LenType == "fixed" {
+		digits = fieldDescription.MaxLen - 1
+	}
+	return iso.AddField(field, formatAmount(amount, digits))
+}
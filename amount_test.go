@@ -0,0 +1,84 @@
+package iso8583
+
+import "testing"
+
+func TestParseAmount(t *testing.T) {
+	ts := []struct {
+		data     string
+		expected int64
+	}{
+		{"C00001500", 1500},
+		{"D00001500", -1500},
+		{"C0", 0},
+	}
+	for _, v := range ts {
+		amount, err := parseAmount(v.data)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", v.data, err.Error())
+			continue
+		}
+		if amount != v.expected {
+			t.Errorf("Expected %d but got %d", v.expected, amount)
+		}
+	}
+
+	for _, data := range []string{"", "C", "X00001500", "C0000150A"} {
+		_, err := parseAmount(data)
+		if err == nil {
+			t.Errorf("should throw an error on %q", data)
+		}
+	}
+}
+
+func TestSignedAmountFields(t *testing.T) {
+	one := NewISOStruct("spec1987.yml", false)
+	one.AddMTI("0200")
+	one.AddField(4, "000000001500")
+	if err := one.AddAmount(28, -250); err != nil {
+		t.Fatalf("failed to add a signed amount: %s", err.Error())
+	}
+	if err := one.AddAmount(4, 100); err == nil {
+		t.Errorf("should not add a signed amount to an unsigned field")
+	}
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack signed amount: %s", err.Error())
+	}
+	expected := "0200" + "1000001000000000" + "000000001500" + "D00000250"
+	if packed != expected {
+		t.Errorf("%s should be %s", packed, expected)
+	}
+
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse signed amount: %s", err.Error())
+	}
+	amount, err := parsed.GetAmount(28)
+	if err != nil || amount != -250 {
+		t.Errorf("Expected -250 but got %d", amount)
+	}
+}
+
+func TestNetSettlementAmount(t *testing.T) {
+	one := NewISOStruct("spec1987.yml", true)
+	one.AddMTI("0500")
+	if err := one.AddAmount(97, -1234567); err != nil {
+		t.Fatalf("failed to add a signed net settlement amount: %s", err.Error())
+	}
+	if value, _ := one.GetField(97); value != "D0000000001234567" {
+		t.Errorf("expected field 97 to be D0000000001234567 found %s", value)
+	}
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack field 97: %s", err.Error())
+	}
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse field 97: %s", err.Error())
+	}
+	if amount, err := parsed.GetAmount(97); err != nil || amount != -1234567 {
+		t.Errorf("expected -1234567 found %d (%v)", amount, err)
+	}
+}
@@ -0,0 +1,71 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// isBCD reports whether a field is a numeric field packed as binary coded decimal
+func isBCD(fieldDescription FieldDescription) bool {
+	return fieldDescription.HeaderHex && fieldDescription.ContentType == "n" && fieldDescription.Contain == ""
+}
+
+// isNibblePacked reports whether a field is packed two characters per
+// byte, bcd numbers and track data held as hex are
+func isNibblePacked(fieldDescription FieldDescription) bool {
+	return isBCD(fieldDescription) || (isTrack2(fieldDescription) && fieldDescription.HeaderHex)
+}
+
+// bcdPadNibble returns the nibble used to fill odd length bcd values,
+// "0" unless the spec asks for "F"
+func bcdPadNibble(fieldDescription FieldDescription) string {
+	if strings.ToUpper(fieldDescription.BcdPad) == "F" {
+		return "f"
+	}
+	return "0"
+}
+
+// packBCD packs a string of digits two per byte, odd length values
+// get a pad nibble in front unless the value is left justified
+func packBCD(digits string, pad string, justify string) (string, error) {
+	for i := 0; i < len(digits); i++ {
+		if digits[i] < '0' || digits[i] > '9' {
+			return "", fmt.Errorf("%q can not be packed as bcd, it contains %q", digits, digits[i])
+		}
+	}
+	return packNibbles(digits, pad, justify)
+}
+
+// packNibbles packs hex digits two per byte, padding odd length values
+// on the side justify leaves free
+func packNibbles(nibbles string, pad string, justify string) (string, error) {
+	if len(nibbles)%2 != 0 {
+		if justify == "left" {
+			nibbles = nibbles + pad
+		} else {
+			nibbles = pad + nibbles
+		}
+	}
+	packed, err := hex.DecodeString(nibbles)
+	if err != nil {
+		return "", err
+	}
+	return string(packed), nil
+}
+
+// unpackBCD turns packed bcd back into a string of length digits
+func unpackBCD(packed string, length int, justify string) string {
+	return trimBCDPad(hex.EncodeToString([]byte(packed)), length, justify)
+}
+
+// trimBCDPad drops the pad nibble an odd length value was packed with
+func trimBCDPad(digits string, length int, justify string) string {
+	if len(digits) <= length {
+		return digits
+	}
+	if justify == "left" {
+		return digits[0:length]
+	}
+	return digits[len(digits)-length:]
+}
@@ -0,0 +1,72 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestPackBCD(t *testing.T) {
+	ts := []struct {
+		digits, pad, justify, expected string
+	}{
+		{"1234", "0", "right", "1234"},
+		{"123", "0", "right", "0123"},
+		{"123", "f", "right", "f123"},
+		{"123", "f", "left", "123f"},
+		{"4761739001010010123", "f", "left", "4761739001010010123f"},
+	}
+	for _, v := range ts {
+		packed, err := packBCD(v.digits, v.pad, v.justify)
+		if err != nil {
+			t.Errorf("failed to pack %s: %s", v.digits, err.Error())
+			continue
+		}
+		if hex.EncodeToString([]byte(packed)) != v.expected {
+			t.Errorf("Expected %s but got %x", v.expected, packed)
+		}
+		unpacked := unpackBCD(packed, len(v.digits), v.justify)
+		if unpacked != v.digits {
+			t.Errorf("Expected %s but got %s", v.digits, unpacked)
+		}
+	}
+
+	_, err := packBCD("12a4", "0", "right")
+	if err == nil {
+		t.Errorf("should throw an error on non numeric digits")
+	}
+}
+
+func TestBCDOddLengthFields(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:  {ContentType: "n", LenType: "fixed", MaxLen: 4, HeaderHex: true},
+		1:  {ContentType: "b", LenType: "fixed", MaxLen: 8, HeaderHex: true},
+		2:  {ContentType: "n", LenType: "llvar", MaxLen: 19, HeaderHex: true, BcdPad: "F", BcdJustify: "left"},
+		22: {ContentType: "n", LenType: "fixed", MaxLen: 3, HeaderHex: true},
+		36: {ContentType: "n", LenType: "lllvar", MaxLen: 104, HeaderHex: true},
+	})
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0200")
+	one.AddField(2, "4761739001010010123")
+	one.AddField(22, "051")
+	one.AddField(36, "12345")
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack bcd message: %s", err.Error())
+	}
+
+	expected := "0200" + "4000040010000000" + "19" + "4761739001010010123f" + "0051" + "0005" + "012345"
+	if hex.EncodeToString([]byte(packed)) != expected {
+		t.Errorf("Expected %s but got %x", expected, packed)
+	}
+
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse bcd message: %s", err.Error())
+	}
+	for field, value := range one.Elements.GetElements() {
+		if parsed.Elements.GetElements()[field] != value {
+			t.Errorf("field %d: expected %s but got %s", field, value, parsed.Elements.GetElements()[field])
+		}
+	}
+}
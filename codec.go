@@ -0,0 +1,124 @@
+package iso8583
+
+import (
+	"fmt"
+	"io"
+)
+
+// TruncatedError is returned when a message ends before all of it is read
+type TruncatedError struct {
+	Field string // field being read, empty for the header
+	Need  int
+	Have  int
+}
+
+func (e *TruncatedError) Error() string {
+	if e.Field == "" {
+		return fmt.Sprintf("message truncated: need %d bytes have %d", e.Need, e.Have)
+	}
+	return fmt.Sprintf("field %s: message truncated: need %d bytes have %d", e.Field, e.Need, e.Have)
+}
+
+// fieldError says which field an error came from,
+// truncation stays a TruncatedError so callers can tell it apart
+func fieldError(field string, err error) error {
+	if truncated, ok := err.(*TruncatedError); ok {
+		truncated.Field = field
+		return truncated
+	}
+	return fmt.Errorf("spec error: field %s: %s", field, err.Error())
+}
+
+// readSize is how much the decoder asks for at a time
+const readSize = 4096
+
+// Decoder reads successive messages of a spec from a stream
+// that carries them back to back without framing
+type Decoder struct {
+	// LazyValidation leaves checking decoded messages to the caller,
+	// as it does for IsoStruct
+	LazyValidation bool
+
+	r       io.Reader
+	message IsoStruct
+	buf     []byte
+	err     error
+}
+
+// NewDecoder returns a Decoder reading messages of spec from r,
+// messages are expected behind the header the spec selects
+func NewDecoder(r io.Reader, spec Spec) *Decoder {
+	header, _ := spec.Header() // the header was checked when the spec was read
+	return &Decoder{r: r, message: IsoStruct{Spec: spec, Header: header}}
+}
+
+// Decode reads the next message into iso, io.EOF is returned once the
+// stream ends between messages and a TruncatedError when it ends inside one.
+// A message that fails validation is still read into iso and the stream
+// goes on with the next one
+func (d *Decoder) Decode(iso *IsoStruct) error {
+	d.message.LazyValidation = d.LazyValidation
+	for {
+		if len(d.buf) > 0 {
+			q, rest, err := d.message.unpack(string(d.buf))
+			if err == nil {
+				d.buf = d.buf[:copy(d.buf, d.buf[len(d.buf)-len(rest):])]
+				*iso = q
+				return iso.check()
+			}
+			if _, ok := err.(*TruncatedError); !ok || d.err != nil {
+				// the message can not be completed, drop what is left of it
+				d.buf = d.buf[:0]
+				return err
+			}
+		} else if d.err != nil {
+			return d.err
+		}
+		d.fill()
+	}
+}
+
+// fill reads more of the stream onto the end of the buffer
+func (d *Decoder) fill() {
+	if cap(d.buf)-len(d.buf) < readSize {
+		buf := make([]byte, len(d.buf), 2*cap(d.buf)+readSize)
+		copy(buf, d.buf)
+		d.buf = buf
+	}
+	n, err := d.r.Read(d.buf[len(d.buf):cap(d.buf)])
+	d.buf = d.buf[:len(d.buf)+n]
+	if err != nil {
+		d.err = err
+	}
+}
+
+// Encoder writes successive messages of a spec to a stream
+type Encoder struct {
+	w      io.Writer
+	spec   Spec
+	header Header
+	buf    []byte
+}
+
+// NewEncoder returns an Encoder writing messages of spec to w
+func NewEncoder(w io.Writer, spec Spec) *Encoder {
+	header, _ := spec.Header() // the header was checked when the spec was read
+	return &Encoder{w: w, spec: spec, header: header}
+}
+
+// Encode packs iso with the encoder's spec and writes it,
+// messages without a header of their own get an empty one of the spec's kind
+func (e *Encoder) Encode(iso *IsoStruct) error {
+	msg := *iso
+	msg.Spec = e.spec
+	if msg.Header == nil {
+		msg.Header = e.header
+	}
+	packed, err := msg.pack()
+	if err != nil {
+		return err
+	}
+	e.buf = append(e.buf[:0], packed...)
+	_, err = e.w.Write(e.buf)
+	return err
+}
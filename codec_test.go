@@ -0,0 +1,176 @@
+package iso8583
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+	"testing/iotest"
+)
+
+func TestEncoderDecoder(t *testing.T) {
+	spec, err := SpecFromFile("spec1987pos.yml")
+	if err != nil {
+		t.Fatalf("failed to read spec: %s", err.Error())
+	}
+
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream, spec)
+	stans := []string{"000001", "000002", "000003"}
+	for i, stan := range stans {
+		one := NewISOStruct("spec1987pos.yml", false)
+		if i == 1 {
+			one.Header = Tpdu{ID: 0x60, Destination: 0x0018}
+		}
+		one.AddMTI("0800")
+		one.AddField(11, stan)
+		one.AddField(41, "77000033")
+		err = enc.Encode(&one)
+		if err != nil {
+			t.Fatalf("failed to encode: %s", err.Error())
+		}
+	}
+
+	dec := NewDecoder(iotest.OneByteReader(&stream), spec)
+	for i, stan := range stans {
+		var parsed IsoStruct
+		err = dec.Decode(&parsed)
+		if err != nil {
+			t.Fatalf("failed to decode message %d: %s", i, err.Error())
+		}
+		if value, _ := parsed.GetField(11); value != stan {
+			t.Errorf("expected field 11 to be %s found %s", stan, value)
+		}
+		if i == 1 && parsed.Header != (Tpdu{ID: 0x60, Destination: 0x0018}) {
+			t.Errorf("unexpected tpdu %#v", parsed.Header)
+		}
+	}
+	var parsed IsoStruct
+	err = dec.Decode(&parsed)
+	if err != io.EOF {
+		t.Errorf("expected io.EOF after the last message found %v", err)
+	}
+}
+
+func TestDecoderTruncated(t *testing.T) {
+	spec, _ := SpecFromFile("spec1987pos.yml")
+	isobyte, _ := hex.DecodeString("60001800000800202001000080000492000000029900183737303030303333003748544c45303331303031303031373730303030333330303030303030378ca64de98ca64de9")
+
+	dec := NewDecoder(bytes.NewReader(isobyte[:len(isobyte)-4]), spec)
+	var parsed IsoStruct
+	err := dec.Decode(&parsed)
+	truncated, ok := err.(*TruncatedError)
+	if !ok {
+		t.Fatalf("expected a TruncatedError found %v", err)
+	}
+	if truncated.Field != "62" {
+		t.Errorf("expected field 62 to be truncated found %s", truncated.Field)
+	}
+	err = dec.Decode(&parsed)
+	if err != io.EOF {
+		t.Errorf("expected io.EOF after the truncated message found %v", err)
+	}
+
+	iso := NewISOStruct("spec1987pos.yml", false)
+	for _, n := range []int{3, 6, 9, 20} {
+		_, err = iso.Parse(string(isobyte[:n]))
+		if _, ok := err.(*TruncatedError); !ok {
+			t.Errorf("expected %d bytes to give a TruncatedError found %v", n, err)
+		}
+	}
+}
+
+func TestDecoderInvalidMessage(t *testing.T) {
+	spec, _ := SpecFromFile("spec1987.yml")
+	var stream bytes.Buffer
+	enc := NewEncoder(&stream, spec)
+	stans := []string{"000001", "00000Z", "000003"}
+	for _, stan := range stans {
+		one := NewISOStruct("spec1987.yml", false)
+		one.LazyValidation = true
+		one.AddMTI("0800")
+		one.AddField(11, stan)
+		one.AddField(41, "77000033")
+		err := enc.Encode(&one)
+		if err != nil {
+			t.Fatalf("failed to encode: %s", err.Error())
+		}
+	}
+	content := stream.Bytes()
+
+	dec := NewDecoder(bytes.NewReader(content), spec)
+	for i, stan := range stans {
+		var parsed IsoStruct
+		err := dec.Decode(&parsed)
+		if i == 1 {
+			errs, ok := err.(ValidationErrors)
+			if !ok || len(errs) != 1 || errs[0].Field != 11 {
+				t.Errorf("expected the second message to fail on field 11 found %v", err)
+			}
+		} else if err != nil {
+			t.Fatalf("failed to decode message %d: %s", i, err.Error())
+		}
+		if value, _ := parsed.GetField(11); value != stan {
+			t.Errorf("expected field 11 to be %s found %s", stan, value)
+		}
+	}
+	var parsed IsoStruct
+	if err := dec.Decode(&parsed); err != io.EOF {
+		t.Errorf("expected io.EOF after the last message found %v", err)
+	}
+
+	lazy := NewDecoder(bytes.NewReader(content), spec)
+	lazy.LazyValidation = true
+	for i := range stans {
+		err := lazy.Decode(&parsed)
+		if err != nil {
+			t.Errorf("expected lazy decoding of message %d to succeed: %s", i, err.Error())
+		}
+	}
+	if errs := parsed.Validate(); errs != nil {
+		t.Errorf("expected the last message to be valid: %s", errs.Error())
+	}
+
+	parser := NewISOStruct("spec1987.yml", false)
+	_, rest, err := parser.parse(string(content))
+	if err != nil {
+		t.Fatalf("failed to parse the first message: %s", err.Error())
+	}
+	_, last, err := parser.parse(rest)
+	if _, ok := err.(ValidationErrors); !ok || len(last) != len(content)/3 {
+		t.Errorf("expected the invalid message to be followed by the last one, %d bytes found %d", len(content)/3, len(last))
+	}
+}
+
+// stopReader fails a decoder that reads on after a whole message
+type stopReader struct{}
+
+func (stopReader) Read(p []byte) (int, error) {
+	return 0, errors.New("read past the message")
+}
+
+func TestDecoderTruncatedSubfield(t *testing.T) {
+	spec, err := Specs["1987"].Builder().Field(48, FieldDescription{ContentType: "ans", LenType: "lllvar", MaxLen: 999, Subfields: []FieldDescription{
+		{ContentType: "ans", LenType: "llvar", MaxLen: 99},
+	}}).Build()
+	if err != nil {
+		t.Fatalf("failed to build spec: %s", err.Error())
+	}
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0100")
+	one.AddField(48, "99XYZ")
+	var stream bytes.Buffer
+	err = NewEncoder(&stream, spec).Encode(&one)
+	if err != nil {
+		t.Fatalf("failed to encode: %s", err.Error())
+	}
+
+	dec := NewDecoder(io.MultiReader(&stream, stopReader{}), spec)
+	var parsed IsoStruct
+	err = dec.Decode(&parsed)
+	if _, ok := err.(*TruncatedError); ok || err == nil || !strings.Contains(err.Error(), "overruns field 48") {
+		t.Errorf("expected subfield 48.1 to overrun its field found %v", err)
+	}
+}
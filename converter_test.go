@@ -61,3 +61,21 @@ func TestBinaryandBack(t *testing.T) {
 		t.Errorf("Hex and Bitmap conversion not working as expected")
 	}
 }
+
+func TestTertiaryBitmapHex(t *testing.T) {
+	tertiary := "a0000000000000008000000000000000" + "0000000000000001"
+	bitArray, err := HexToBitmapArray(tertiary)
+	if err != nil {
+		t.Fatalf("isn't parsing valid hex string")
+	}
+	if len(bitArray) != 192 {
+		t.Errorf("HexToBitmapArray() is generating a length %d instead of 192", len(bitArray))
+	}
+	if bitArray[0] != 1 || bitArray[64] != 1 || bitArray[191] != 1 {
+		t.Errorf("HexToBitmapArray() did not set the expected bits")
+	}
+	hexString, err := BitMapArrayToHex(bitArray)
+	if err != nil || hexString != tertiary {
+		t.Errorf("Hex and Bitmap conversion not working as expected for tertiary bitmaps")
+	}
+}
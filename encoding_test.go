@@ -0,0 +1,77 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestEncodeText(t *testing.T) {
+	ts := []struct {
+		encoding, text, expected string
+	}{
+		{"ebcdic", "0200", "f0f2f0f0"},
+		{"cp037", "AZ az[]", "c1e94081a9babb"},
+		{"cp1047", "AZ az[]", "c1e94081a9adbd"},
+		{"ascii", "0200", "30323030"},
+		{"", "0200", "30323030"},
+	}
+	for _, v := range ts {
+		encoded, err := encodeText(v.encoding, v.text)
+		if err != nil {
+			t.Errorf("failed to encode %q as %s: %s", v.text, v.encoding, err.Error())
+			continue
+		}
+		if hex.EncodeToString([]byte(encoded)) != v.expected {
+			t.Errorf("Expected %s but got %x", v.expected, encoded)
+		}
+		decoded, _ := decodeText(v.encoding, encoded)
+		if decoded != v.text {
+			t.Errorf("Expected %s but got %s", v.text, decoded)
+		}
+	}
+
+	_, err := encodeText("cp500", "0200")
+	if err == nil {
+		t.Errorf("should throw an error on an unknown encoding")
+	}
+}
+
+func TestEbcdicMessage(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:  {ContentType: "n", LenType: "fixed", MaxLen: 4, Encoding: "ebcdic"},
+		1:  {ContentType: "b", LenType: "fixed", MaxLen: 8, Encoding: "ebcdic"},
+		2:  {ContentType: "n", LenType: "llvar", MaxLen: 19, Encoding: "ebcdic"},
+		3:  {ContentType: "n", LenType: "fixed", MaxLen: 6, Encoding: "ebcdic"},
+		41: {ContentType: "ans", LenType: "fixed", MaxLen: 8, Encoding: "cp1047"},
+	})
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0200")
+	one.AddField(2, "4761739001010010")
+	one.AddField(3, "000010")
+	one.AddField(41, "TERM[01]")
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack ebcdic message: %s", err.Error())
+	}
+
+	// 0200, bitmap 6000000000800000, 16, PAN, processing code, terminal id
+	expected := "f0f2f0f0" + "f6f0f0f0f0f0f0f0f0f0f8f0f0f0f0f0" +
+		"f1f6" + "f4f7f6f1f7f3f9f0f0f1f0f1f0f0f1f0" + "f0f0f0f0f1f0" + "e3c5d9d4adf0f1bd"
+	if hex.EncodeToString([]byte(packed)) != expected {
+		t.Errorf("Expected %s but got %x", expected, packed)
+	}
+
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse ebcdic message: %s", err.Error())
+	}
+	if parsed.Mti.String() != "0200" {
+		t.Errorf("Expected mti 0200 but got %s", parsed.Mti.String())
+	}
+	for field, value := range one.Elements.GetElements() {
+		if parsed.Elements.GetElements()[field] != value {
+			t.Errorf("field %d: expected %s but got %s", field, value, parsed.Elements.GetElements()[field])
+		}
+	}
+}
@@ -0,0 +1,106 @@
+package iso8583
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// FieldValidator checks a field value beyond its content type and
+// lengths, the error it returns says what is wrong with the value
+type FieldValidator func(data string) error
+
+// FieldValidators holds the validators a spec can name in the Validators
+// of a field, the ones shipped with the package are luhn, yymm and iso4217.
+// A validator for a single spec is attached with SpecBuilder.Validator
+var FieldValidators = map[string]FieldValidator{
+	"luhn":    LuhnValidator,
+	"yymm":    YYMMValidator,
+	"iso4217": ISO4217Validator,
+}
+
+// RegisterValidator makes a validator available to specs under name, it is
+// meant to be called from init before specs naming it are read and panics
+// if the name is taken
+func RegisterValidator(name string, validator FieldValidator) {
+	if _, ok := FieldValidators[name]; ok {
+		panic(fmt.Errorf("validator %s is already registered", name))
+	}
+	FieldValidators[name] = validator
+}
+
+// validator finds a validator by name, among those attached to the spec
+// first and then among the registered FieldValidators
+func (s Spec) validator(name string) (FieldValidator, bool) {
+	if validator, ok := s.validators[name]; ok {
+		return validator, true
+	}
+	validator, ok := FieldValidators[name]
+	return validator, ok
+}
+
+// LuhnValidator checks the Luhn check digit of a card number
+func LuhnValidator(data string) error {
+	if len(data) < 2 {
+		return errors.New("expected a card number of at least 2 digits")
+	}
+	sum := 0
+	double := false
+	for i := len(data) - 1; i >= 0; i-- {
+		c := data[i]
+		if c < '0' || c > '9' {
+			return fmt.Errorf("expected digits found %q at position %d", c, i+1)
+		}
+		digit := int(c - '0')
+		if double {
+			digit *= 2
+			if digit > 9 {
+				digit -= 9
+			}
+		}
+		sum += digit
+		double = !double
+	}
+	if sum%10 != 0 {
+		return errors.New("failed the luhn check")
+	}
+	return nil
+}
+
+// YYMMValidator checks a year and month such as an expiration date
+func YYMMValidator(data string) error {
+	if len(data) != 4 || checkCharacters(data, "digits", func(c byte) bool { return c >= '0' && c <= '9' }) != nil {
+		return fmt.Errorf("expected YYMM found %q", data)
+	}
+	if month := data[2:]; month < "01" || month > "12" {
+		return fmt.Errorf("%s is an invalid month", month)
+	}
+	return nil
+}
+
+// ISO4217Validator checks a numeric currency code
+func ISO4217Validator(data string) error {
+	if !iso4217[data] {
+		return fmt.Errorf("%q is not an ISO 4217 currency code", data)
+	}
+	return nil
+}
+
+// iso4217 are the numeric currency codes in use, fund codes included
+var iso4217 = func() map[string]bool {
+	codes := map[string]bool{}
+	for _, code := range strings.Fields(`
+		008 012 032 036 044 048 050 051 052 060 064 068 072 084 090 096 104 108
+		116 124 132 136 144 152 156 170 174 188 192 203 208 214 222 230 232 238
+		242 262 270 292 320 324 328 332 340 344 348 352 356 360 364 368 376 388
+		392 398 400 404 408 410 414 417 418 422 426 430 434 446 454 458 462 480
+		484 496 498 504 512 516 524 532 533 548 554 558 566 578 586 590 598 600
+		604 608 634 643 646 654 682 690 694 702 704 706 710 728 748 752 756 760
+		764 776 780 784 788 800 807 818 826 834 840 858 860 882 886 901 924 925
+		926 927 928 929 930 931 932 933 934 936 938 940 941 943 944 946 947 948
+		949 950 951 952 953 960 965 967 968 969 970 971 972 973 975 976 977 978
+		979 980 981 984 985 986 990 994 997`) {
+		codes[code] = true
+	}
+	return codes
+}()
@@ -0,0 +1,161 @@
+package iso8583
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestFieldValidators(t *testing.T) {
+	tests := []struct {
+		validator string
+		data      string
+		valid     bool
+	}{
+		{"luhn", "4111111111111111", true},
+		{"luhn", "4111111111111112", false},
+		{"luhn", "79927398713", true},
+		{"luhn", "7992739871x", false},
+		{"luhn", "0", false},
+		{"yymm", "2612", true},
+		{"yymm", "2601", true},
+		{"yymm", "2613", false},
+		{"yymm", "2600", false},
+		{"yymm", "26 1", false},
+		{"yymm", "261", false},
+		{"iso4217", "840", true},
+		{"iso4217", "978", true},
+		{"iso4217", "USD", false},
+		{"iso4217", "123", false},
+	}
+	for _, test := range tests {
+		err := FieldValidators[test.validator](test.data)
+		if test.valid && err != nil {
+			t.Errorf("%s: expected %q to be valid: %s", test.validator, test.data, err.Error())
+		}
+		if !test.valid && err == nil {
+			t.Errorf("%s: expected %q to be refused", test.validator, test.data)
+		}
+	}
+}
+
+func TestSpecValidators(t *testing.T) {
+	spec, err := SpecFromBytes([]byte("extends: spec1987.yml\n2:\n  Validators: [luhn]\n14:\n  Validators: [yymm]\n49:\n  Validators: [iso4217]\n"))
+	if err != nil {
+		t.Fatalf("failed to read spec naming validators: %s", err.Error())
+	}
+	if pan, _ := spec.Field(2); len(pan.Validators) != 1 || pan.Validators[0] != "luhn" || pan.MaxLen != 19 {
+		t.Errorf("expected field 2 to keep its description and add luhn found %#v", pan)
+	}
+
+	one := NewISOStructFromSpec(spec, false)
+	err = one.AddField(2, "4111111111111112")
+	if invalid, ok := err.(ValidationError); !ok || invalid.Rule != "luhn" {
+		t.Errorf("expected field 2 to fail the luhn check found %v", err)
+	}
+
+	one.LazyValidation = true
+	one.AddMTI("0200")
+	one.AddField(2, "4111111111111111")
+	one.AddField(14, "2613")
+	one.AddField(49, "999x")
+	one.AddField(11, "000001")
+	errs := one.Validate()
+	if len(errs) != 2 || errs[0].Field != 14 || errs[0].Rule != "yymm" || errs[1].Field != 49 || errs[1].Rule != RuleLength {
+		t.Errorf("expected field 14 to fail yymm and field 49 its length first found %v", errs)
+	}
+
+	_, err = SpecFromBytes([]byte("extends: spec1987.yml\n2:\n  Validators: [mod97]\n"))
+	if err == nil || !strings.Contains(err.Error(), "mod97 is an unknown validator") {
+		t.Errorf("expected an unknown validator to fail found %v", err)
+	}
+
+	built, err := spec.Builder().Validators(11, "luhn").Build()
+	if err != nil {
+		t.Fatalf("failed to attach a validator: %s", err.Error())
+	}
+	if stan, _ := spec.Field(11); len(stan.Validators) != 0 {
+		t.Errorf("expected the original spec to be untouched found %#v", stan)
+	}
+	two := NewISOStructFromSpec(built, false)
+	if err = two.AddField(11, "000001"); err == nil {
+		t.Errorf("expected field 11 to fail the luhn check")
+	}
+}
+
+func TestSpecValidatorFunc(t *testing.T) {
+	parity := func(remainder byte) FieldValidator {
+		return func(data string) error {
+			if (data[len(data)-1]-'0')%2 != remainder {
+				return errors.New("wrong parity")
+			}
+			return nil
+		}
+	}
+	even, err := Specs["1987"].Builder().Validator(11, "parity", parity(0)).Build()
+	if err != nil {
+		t.Fatalf("failed to attach a validator func: %s", err.Error())
+	}
+	odd, err := Specs["1987"].Builder().Validator(11, "parity", parity(1)).Build()
+	if err != nil {
+		t.Fatalf("failed to attach a validator func: %s", err.Error())
+	}
+	if _, ok := FieldValidators["parity"]; ok {
+		t.Errorf("expected the validator to stay out of the registry")
+	}
+
+	one := NewISOStructFromSpec(even, false)
+	if err = one.AddField(11, "000002"); err != nil {
+		t.Errorf("expected an even stan to be valid: %s", err.Error())
+	}
+	err = one.AddField(11, "000003")
+	if invalid, ok := err.(ValidationError); !ok || invalid.Rule != "parity" {
+		t.Errorf("expected an odd stan to fail the parity rule found %v", err)
+	}
+	two := NewISOStructFromSpec(odd, false)
+	if err = two.AddField(11, "000003"); err != nil {
+		t.Errorf("expected the other spec to keep its own validator: %s", err.Error())
+	}
+
+	// the validator goes along to specs built from the spec
+	built, err := even.Builder().Validators(4, "parity").Build()
+	if err != nil {
+		t.Fatalf("failed to name a validator attached to the spec: %s", err.Error())
+	}
+	three := NewISOStructFromSpec(built, false)
+	if err = three.AddField(4, "000000000001"); err == nil {
+		t.Errorf("expected field 4 to fail the parity rule")
+	}
+	if _, err = Specs["1987"].Builder().Validators(4, "parity").Build(); err == nil || !strings.Contains(err.Error(), "parity is an unknown validator") {
+		t.Errorf("expected other specs not to know the validator found %v", err)
+	}
+}
+
+func TestRegisterValidator(t *testing.T) {
+	RegisterValidator("test-even", func(data string) error {
+		if (data[len(data)-1]-'0')%2 != 0 {
+			return errors.New("expected an even number")
+		}
+		return nil
+	})
+	defer delete(FieldValidators, "test-even")
+
+	spec, err := Specs["1987"].Builder().Validators(11, "test-even").Build()
+	if err != nil {
+		t.Fatalf("failed to attach a registered validator: %s", err.Error())
+	}
+	one := NewISOStructFromSpec(spec, false)
+	if err = one.AddField(11, "000002"); err != nil {
+		t.Errorf("expected an even stan to be valid: %s", err.Error())
+	}
+	if err = one.AddField(11, "000003"); err == nil || err.Error() != "field 11: expected an even number" {
+		t.Errorf("expected an odd stan to be refused found %v", err)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering luhn again to panic")
+		}
+	}()
+	RegisterValidator("luhn", LuhnValidator)
+}
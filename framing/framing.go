@@ -0,0 +1,163 @@
+// Package framing reads and writes the length prefix hosts put in front
+// of each iso8583 message on a stream connection
+package framing
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// Framer reads and writes single length prefixed messages
+type Framer interface {
+	// ReadFrame reads one complete message off r without its prefix
+	ReadFrame(r io.Reader) ([]byte, error)
+	// WriteFrame writes msg to w behind its length prefix
+	WriteFrame(w io.Writer, msg []byte) error
+}
+
+// lengthPrefix is a Framer for a prefix of size bytes holding the length
+// of the message, inclusive prefixes count their own bytes as well
+type lengthPrefix struct {
+	size      int
+	max       int
+	inclusive bool
+	encode    func(length int, prefix []byte)
+	decode    func(prefix []byte) (int, error)
+}
+
+// NewBinary returns a Framer for a 2 byte big endian binary length
+func NewBinary(inclusive bool) Framer {
+	return &lengthPrefix{
+		size:      2,
+		max:       0xffff,
+		inclusive: inclusive,
+		encode: func(length int, prefix []byte) {
+			binary.BigEndian.PutUint16(prefix, uint16(length))
+		},
+		decode: func(prefix []byte) (int, error) {
+			return int(binary.BigEndian.Uint16(prefix)), nil
+		},
+	}
+}
+
+// NewASCII returns a Framer for a 4 digit ascii length
+func NewASCII(inclusive bool) Framer {
+	return &lengthPrefix{
+		size:      4,
+		max:       9999,
+		inclusive: inclusive,
+		encode: func(length int, prefix []byte) {
+			copy(prefix, fmt.Sprintf("%04d", length))
+		},
+		decode: func(prefix []byte) (int, error) {
+			length, err := strconv.Atoi(string(prefix))
+			if err != nil || length < 0 {
+				return 0, fmt.Errorf("%q is an invalid ascii length", prefix)
+			}
+			return length, nil
+		},
+	}
+}
+
+// NewBCD returns a Framer for a 4 digit length packed into 2 bcd bytes
+func NewBCD(inclusive bool) Framer {
+	return &lengthPrefix{
+		size:      2,
+		max:       9999,
+		inclusive: inclusive,
+		encode: func(length int, prefix []byte) {
+			packed, _ := hex.DecodeString(fmt.Sprintf("%04d", length))
+			copy(prefix, packed)
+		},
+		decode: func(prefix []byte) (int, error) {
+			digits := hex.EncodeToString(prefix)
+			length, err := strconv.Atoi(digits)
+			if err != nil {
+				return 0, fmt.Errorf("%s is an invalid bcd length", digits)
+			}
+			return length, nil
+		},
+	}
+}
+
+// ReadFrame implements Framer
+func (l *lengthPrefix) ReadFrame(r io.Reader) ([]byte, error) {
+	prefix := make([]byte, l.size)
+	_, err := io.ReadFull(r, prefix)
+	if err != nil {
+		return nil, err
+	}
+	length, err := l.decode(prefix)
+	if err != nil {
+		return nil, err
+	}
+	if l.inclusive {
+		if length < l.size {
+			return nil, fmt.Errorf("length %d is shorter than its own prefix", length)
+		}
+		length -= l.size
+	}
+
+	msg := make([]byte, length)
+	_, err = io.ReadFull(r, msg)
+	if err == io.EOF {
+		err = io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, err
+	}
+	return msg, nil
+}
+
+// WriteFrame implements Framer, prefix and message go out in a single write
+func (l *lengthPrefix) WriteFrame(w io.Writer, msg []byte) error {
+	length := len(msg)
+	if l.inclusive {
+		length += l.size
+	}
+	if length > l.max {
+		return fmt.Errorf("message of %d bytes does not fit a %d byte prefix", len(msg), l.size)
+	}
+
+	frame := make([]byte, l.size, l.size+len(msg))
+	l.encode(length, frame)
+	frame = append(frame, msg...)
+	_, err := w.Write(frame)
+	return err
+}
+
+// Reader yields complete messages from a stream
+type Reader struct {
+	r      io.Reader
+	framer Framer
+}
+
+// NewReader returns a Reader for messages framed by framer
+func NewReader(r io.Reader, framer Framer) *Reader {
+	return &Reader{r: r, framer: framer}
+}
+
+// ReadMessage returns the next message ready to be parsed,
+// io.EOF is returned once the stream ends between messages
+func (r *Reader) ReadMessage() ([]byte, error) {
+	return r.framer.ReadFrame(r.r)
+}
+
+// Writer frames messages onto a stream
+type Writer struct {
+	w      io.Writer
+	framer Framer
+}
+
+// NewWriter returns a Writer that frames messages with framer
+func NewWriter(w io.Writer, framer Framer) *Writer {
+	return &Writer{w: w, framer: framer}
+}
+
+// WriteMessage writes a packed message behind its length prefix
+func (w *Writer) WriteMessage(msg []byte) error {
+	return w.framer.WriteFrame(w.w, msg)
+}
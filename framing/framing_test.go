@@ -0,0 +1,96 @@
+package framing
+
+import (
+	"bytes"
+	"encoding/hex"
+	"io"
+	"testing"
+)
+
+func TestFramers(t *testing.T) {
+	msg := []byte("0800 hello")
+	tests := []struct {
+		name   string
+		framer Framer
+		prefix string
+	}{
+		{"binary", NewBinary(false), "000a"},
+		{"binary inclusive", NewBinary(true), "000c"},
+		{"ascii", NewASCII(false), "30303130"},
+		{"ascii inclusive", NewASCII(true), "30303134"},
+		{"bcd", NewBCD(false), "0010"},
+		{"bcd inclusive", NewBCD(true), "0012"},
+	}
+
+	for _, test := range tests {
+		var buf bytes.Buffer
+		err := test.framer.WriteFrame(&buf, msg)
+		if err != nil {
+			t.Errorf("%s: failed to write frame: %s", test.name, err.Error())
+			continue
+		}
+		expected := test.prefix + hex.EncodeToString(msg)
+		if hex.EncodeToString(buf.Bytes()) != expected {
+			t.Errorf("%s: %x should be %s", test.name, buf.Bytes(), expected)
+		}
+
+		read, err := test.framer.ReadFrame(&buf)
+		if err != nil {
+			t.Errorf("%s: failed to read frame: %s", test.name, err.Error())
+			continue
+		}
+		if !bytes.Equal(read, msg) {
+			t.Errorf("%s: read %q should be %q", test.name, read, msg)
+		}
+	}
+}
+
+func TestFramerErrors(t *testing.T) {
+	_, err := NewBinary(false).ReadFrame(bytes.NewReader([]byte{0x00, 0x05, '0', '8'}))
+	if err != io.ErrUnexpectedEOF {
+		t.Errorf("expected truncated frame to give io.ErrUnexpectedEOF found %v", err)
+	}
+	_, err = NewASCII(false).ReadFrame(bytes.NewReader([]byte("00x5hello")))
+	if err == nil {
+		t.Errorf("expected invalid ascii length to fail")
+	}
+	_, err = NewBCD(false).ReadFrame(bytes.NewReader([]byte{0x00, 0x1f}))
+	if err == nil {
+		t.Errorf("expected invalid bcd length to fail")
+	}
+	_, err = NewBinary(true).ReadFrame(bytes.NewReader([]byte{0x00, 0x01}))
+	if err == nil {
+		t.Errorf("expected inclusive length shorter than the prefix to fail")
+	}
+	err = NewASCII(false).WriteFrame(&bytes.Buffer{}, make([]byte, 10000))
+	if err == nil {
+		t.Errorf("expected oversized message to fail")
+	}
+}
+
+func TestReaderWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, NewBinary(false))
+	messages := []string{"first", "", "third message"}
+	for _, msg := range messages {
+		err := w.WriteMessage([]byte(msg))
+		if err != nil {
+			t.Fatalf("failed to write message: %s", err.Error())
+		}
+	}
+
+	r := NewReader(&buf, NewBinary(false))
+	for _, msg := range messages {
+		read, err := r.ReadMessage()
+		if err != nil {
+			t.Fatalf("failed to read message: %s", err.Error())
+		}
+		if string(read) != msg {
+			t.Errorf("read %q should be %q", read, msg)
+		}
+	}
+	_, err := r.ReadMessage()
+	if err != io.EOF {
+		t.Errorf("expected io.EOF at the end of the stream found %v", err)
+	}
+}
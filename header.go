@@ -0,0 +1,189 @@
+package iso8583
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+)
+
+// Header is sent in front of the mti by some networks and terminals,
+// the zero value of an implementation can be used to parse one
+type Header interface {
+	// Pack returns the header of a message whose body is length bytes long
+	Pack(length int) ([]byte, error)
+	// Unpack reads a header of the same kind off the front of data
+	// and returns it along with the rest of the message
+	Unpack(data []byte) (Header, []byte, error)
+}
+
+// tpduLength is the length of a tpdu on the wire
+const tpduLength = 5
+
+// Tpdu is the 5 byte transport protocol data unit used by pos terminals,
+// the network international identifiers are kept as they are sent
+// so nii 018 reads 0x0018
+type Tpdu struct {
+	ID          byte
+	Destination uint16
+	Source      uint16
+}
+
+// Pack implements Header
+func (t Tpdu) Pack(length int) ([]byte, error) {
+	tpdu := make([]byte, tpduLength)
+	tpdu[0] = t.ID
+	binary.BigEndian.PutUint16(tpdu[1:3], t.Destination)
+	binary.BigEndian.PutUint16(tpdu[3:5], t.Source)
+	return tpdu, nil
+}
+
+// Unpack implements Header
+func (t Tpdu) Unpack(data []byte) (Header, []byte, error) {
+	if len(data) < tpduLength {
+		return nil, nil, &TruncatedError{Need: tpduLength, Have: len(data)}
+	}
+	tpdu := Tpdu{
+		ID:          data[0],
+		Destination: binary.BigEndian.Uint16(data[1:3]),
+		Source:      binary.BigEndian.Uint16(data[3:5]),
+	}
+	return tpdu, data[tpduLength:], nil
+}
+
+// Response returns the tpdu for a reply to t,
+// destination and source are swapped so it goes back where it came from
+func (t Tpdu) Response() Tpdu {
+	return Tpdu{ID: t.ID, Destination: t.Source, Source: t.Destination}
+}
+
+// visaHeaderLength is the length of a Visa Base I message header
+const visaHeaderLength = 22
+
+// VisaHeader is the 22 byte Visa Base I message header,
+// station ids are held as 6 digit strings
+type VisaHeader struct {
+	Format        byte
+	TextFormat    byte
+	MessageLength int
+	Destination   string
+	Source        string
+	RoundTrip     byte
+	BaseIFlags    [2]byte
+	MessageStatus [3]byte
+	BatchNumber   byte
+	Reserved      [3]byte
+	UserInfo      byte
+}
+
+// Pack implements Header, the total message length is
+// worked out from the length of the body
+func (v VisaHeader) Pack(length int) ([]byte, error) {
+	total := visaHeaderLength + length
+	if total > 0xffff {
+		return nil, fmt.Errorf("message length %d does not fit a visa header", total)
+	}
+	destination, err := packStationID(v.Destination)
+	if err != nil {
+		return nil, err
+	}
+	source, err := packStationID(v.Source)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, 0, visaHeaderLength)
+	header = append(header, visaHeaderLength, v.Format, v.TextFormat)
+	header = append(header, byte(total>>8), byte(total))
+	header = append(header, destination...)
+	header = append(header, source...)
+	header = append(header, v.RoundTrip)
+	header = append(header, v.BaseIFlags[:]...)
+	header = append(header, v.MessageStatus[:]...)
+	header = append(header, v.BatchNumber)
+	header = append(header, v.Reserved[:]...)
+	header = append(header, v.UserInfo)
+	return header, nil
+}
+
+// Unpack implements Header
+func (v VisaHeader) Unpack(data []byte) (Header, []byte, error) {
+	var h VisaHeader
+	if len(data) < visaHeaderLength {
+		return nil, nil, &TruncatedError{Need: visaHeaderLength, Have: len(data)}
+	}
+	if data[0] != visaHeaderLength {
+		return nil, nil, fmt.Errorf("expected a visa header length of %d found %d", visaHeaderLength, data[0])
+	}
+	h.Format = data[1]
+	h.TextFormat = data[2]
+	h.MessageLength = int(binary.BigEndian.Uint16(data[3:5]))
+	h.Destination = hex.EncodeToString(data[5:8])
+	h.Source = hex.EncodeToString(data[8:11])
+	h.RoundTrip = data[11]
+	copy(h.BaseIFlags[:], data[12:14])
+	copy(h.MessageStatus[:], data[14:17])
+	h.BatchNumber = data[17]
+	copy(h.Reserved[:], data[18:21])
+	h.UserInfo = data[21]
+	return h, data[visaHeaderLength:], nil
+}
+
+// packStationID packs a 6 digit station id into 3 bcd bytes
+func packStationID(id string) ([]byte, error) {
+	if id == "" {
+		id = "000000"
+	}
+	if len(id) != 6 {
+		return nil, fmt.Errorf("station id %s should be 6 digits", id)
+	}
+	if _, err := strconv.ParseUint(id, 10, 32); err != nil {
+		return nil, fmt.Errorf("station id %s should be 6 digits", id)
+	}
+	return hex.DecodeString(id)
+}
+
+// FixedHeader is a raw header of a fixed number of bytes
+type FixedHeader struct {
+	Length int
+	Data   []byte
+}
+
+// Pack implements Header, short data is padded with zeros
+func (f FixedHeader) Pack(length int) ([]byte, error) {
+	if len(f.Data) > f.Length {
+		return nil, fmt.Errorf("header of %d bytes does not fit in %d", len(f.Data), f.Length)
+	}
+	header := make([]byte, f.Length)
+	copy(header, f.Data)
+	return header, nil
+}
+
+// Unpack implements Header
+func (f FixedHeader) Unpack(data []byte) (Header, []byte, error) {
+	if len(data) < f.Length {
+		return nil, nil, &TruncatedError{Need: f.Length, Have: len(data)}
+	}
+	header := make([]byte, f.Length)
+	copy(header, data)
+	return FixedHeader{Length: f.Length, Data: header}, data[f.Length:], nil
+}
+
+// newHeader returns the header selected by a spec
+func newHeader(kind string, length int) (Header, error) {
+	switch kind {
+	case "":
+		return nil, nil
+	case "tpdu":
+		return Tpdu{}, nil
+	case "visa":
+		return VisaHeader{}, nil
+	case "fixed":
+		if length < 1 {
+			return nil, fmt.Errorf("spec error: fixed header needs a headerlength")
+		}
+		return FixedHeader{Length: length}, nil
+	default:
+		return nil, fmt.Errorf("spec error: %s is an invalid header", kind)
+	}
+}
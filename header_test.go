@@ -0,0 +1,152 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestVisaHeader(t *testing.T) {
+	one := NewISOStruct("spec1987.yml", false)
+	one.Header = VisaHeader{Format: 0x01, TextFormat: 0x02, Destination: "123456", Source: "000001"}
+	one.AddMTI("0800")
+	one.AddField(11, "000001")
+
+	packed, err := one.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	if len(packed) != 22+4+16+6 {
+		t.Fatalf("unexpected packed length %d", len(packed))
+	}
+	if hex.EncodeToString(packed[:11]) != "1601020030123456000001" {
+		t.Errorf("unexpected visa header %x", packed[:22])
+	}
+
+	parsed := NewISOStruct("spec1987.yml", false)
+	parsed.Header = VisaHeader{}
+	err = parsed.Unpack(packed)
+	if err != nil {
+		t.Fatalf("failed to unpack: %s", err.Error())
+	}
+	header, ok := parsed.Header.(VisaHeader)
+	if !ok {
+		t.Fatalf("expected a visa header found %T", parsed.Header)
+	}
+	if header.MessageLength != len(packed) || header.Destination != "123456" || header.Source != "000001" {
+		t.Errorf("unexpected visa header %#v", header)
+	}
+	if value, _ := parsed.GetField(11); value != "000001" {
+		t.Errorf("expected field 11 to be 000001 found %s", value)
+	}
+
+	_, _, err = VisaHeader{}.Unpack(packed[:10])
+	if err == nil {
+		t.Errorf("expected short visa header to fail")
+	}
+}
+
+func TestFixedHeader(t *testing.T) {
+	header := FixedHeader{Length: 4, Data: []byte("AB")}
+	packed, err := header.Pack(0)
+	if err != nil || string(packed) != "AB\x00\x00" {
+		t.Errorf("unexpected fixed header %q", packed)
+	}
+
+	read, rest, err := FixedHeader{Length: 4}.Unpack([]byte("ISO10800"))
+	if err != nil {
+		t.Fatalf("failed to unpack fixed header: %s", err.Error())
+	}
+	if string(read.(FixedHeader).Data) != "ISO1" || string(rest) != "0800" {
+		t.Errorf("unexpected fixed header %q rest %q", read.(FixedHeader).Data, rest)
+	}
+
+	_, err = FixedHeader{Length: 1, Data: []byte("AB")}.Pack(0)
+	if err == nil {
+		t.Errorf("expected oversized fixed header to fail")
+	}
+}
+
+// headerTestFields are the fields every spec needs
+const headerTestFields = `
+0:
+  ContentType: "n"
+  LenType: fixed
+  MaxLen: 4
+1:
+  ContentType: "b"
+  LenType: fixed
+  MaxLen: 8
+`
+
+func TestSpecHeader(t *testing.T) {
+	tests := []struct {
+		yml    string
+		header Header
+		fail   bool
+	}{
+		{"", nil, false},
+		{"header: tpdu\n", Tpdu{}, false},
+		{"header: visa\n", VisaHeader{}, false},
+		{"header: fixed\nheaderlength: 10\n", FixedHeader{Length: 10}, false},
+		{"header: fixed\n", nil, true},
+		{"header: mastercard\n", nil, true},
+		{"headers: tpdu\n", nil, true},
+	}
+
+	for _, test := range tests {
+		var s Spec
+		err := s.readFromBytes([]byte(test.yml + headerTestFields))
+		if test.fail {
+			if err == nil {
+				t.Errorf("expected %q to fail", test.yml)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("failed to read %q: %s", test.yml, err.Error())
+			continue
+		}
+		header, _ := s.Header()
+		if fixed, ok := test.header.(FixedHeader); ok {
+			if header.(FixedHeader).Length != fixed.Length {
+				t.Errorf("expected %#v found %#v", test.header, header)
+			}
+		} else if header != test.header {
+			t.Errorf("expected %#v found %#v", test.header, header)
+		}
+	}
+
+	s, _ := SpecFromFile("spec1987pos.yml")
+	if header, _ := s.Header(); header != (Tpdu{}) {
+		t.Errorf("expected spec1987pos.yml to use a tpdu found %#v", header)
+	}
+}
+
+func TestTpduResponse(t *testing.T) {
+	isobyte, _ := hex.DecodeString("60001800000800202001000080000492000000029900183737303030303333003748544c45303331303031303031373730303030333330303030303030378ca64de98ca64de9")
+
+	request := NewISOStruct("spec1987pos.yml", false)
+	err := request.Unpack(isobyte)
+	if err != nil {
+		t.Fatalf("failed to unpack request: %s", err.Error())
+	}
+	tpdu, ok := request.Header.(Tpdu)
+	if !ok {
+		t.Fatalf("expected a tpdu found %T", request.Header)
+	}
+	if tpdu != (Tpdu{ID: 0x60, Destination: 0x0018, Source: 0x0000}) {
+		t.Errorf("unexpected tpdu %#v", tpdu)
+	}
+
+	response := NewISOStruct("spec1987pos.yml", false)
+	response.Header = tpdu.Response()
+	response.AddMTI("0810")
+	response.AddField(39, "00")
+	packed, err := response.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack response: %s", err.Error())
+	}
+	if hex.EncodeToString(packed[:5]) != "6000000018" {
+		t.Errorf("expected response tpdu 6000000018 found %x", packed[:5])
+	}
+}
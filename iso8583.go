@@ -3,6 +3,7 @@ package iso8583
 import (
 	"encoding/hex"
 	"fmt"
+	"sort"
 	"strconv"
 )
 
@@ -32,12 +33,48 @@ type IsoStruct struct {
 	Mti      MtiType
 	Bitmap   []int64
 	Elements ElementsType
-	Tpdu     []byte
+	Header   Header
+
+	// LazyValidation leaves checking field values against the spec
+	// to Validate instead of AddField and Parse
+	LazyValidation bool
+
+	chipData  map[int64]*TLVList
+	subfields map[int64]map[string]string
+}
+
+// Pack packs the mti, bitmap and elements into the bytes
+// that are sent on the wire
+func (iso *IsoStruct) Pack() ([]byte, error) {
+	str, err := iso.pack()
+	if err != nil {
+		return nil, err
+	}
+	return []byte(str), nil
+}
+
+// MarshalBinary implements encoding.BinaryMarshaler using Pack
+func (iso *IsoStruct) MarshalBinary() ([]byte, error) {
+	return iso.Pack()
 }
 
-// ToString packs the mti, bitmap and elements into a string
+// ToString packs the mti, bitmap and elements into a string,
+// prefer Pack as most wire formats are binary
 func (iso *IsoStruct) ToString() (string, error) {
+	return iso.pack()
+}
+
+func (iso *IsoStruct) pack() (string, error) {
 	var str string
+	err := iso.packChipData()
+	if err != nil {
+		return str, err
+	}
+	if !iso.LazyValidation {
+		if errs := iso.checkPresence(); errs != nil {
+			return str, errs
+		}
+	}
 	// get done with the mti and the bitmap
 	bitmapString, err := BitMapArrayToHex(iso.Bitmap)
 	if err != nil {
@@ -56,24 +93,35 @@ func (iso *IsoStruct) ToString() (string, error) {
 			bitmapByte, _ = hex.DecodeString(bitmapString)
 		}
 
-		var isomsgByte []byte
-		if len(iso.Tpdu) > 0 {
-			isomsgByte = append(mtiByte, bitmapByte...)
-			isomsgByte = append(iso.Tpdu, isomsgByte...)
-		} else {
-			isomsgByte = append(mtiByte, bitmapByte...)
-		}
+		isomsgByte := append(mtiByte, bitmapByte...)
+		return iso.packHeader(string(isomsgByte) + elementsStr)
+	}
 
-		return string(isomsgByte) + elementsStr, nil
+	header, err := encodeText(iso.Spec.fields[0].Encoding, iso.Mti.String())
+	if err != nil {
+		return str, fmt.Errorf("spec error: field 0: %s", err.Error())
+	}
+	if !iso.Spec.fields[1].HeaderHex {
+		bitmapString, err = encodeText(iso.Spec.fields[1].Encoding, bitmapString)
+		if err != nil {
+			return str, fmt.Errorf("spec error: field 1: %s", err.Error())
+		}
 	}
+	header = header + bitmapString
 
-	fmt.Printf("create message, tpdu %v\n", iso.Tpdu)
-	if len(iso.Tpdu) > 0 {
-		str = string(iso.Tpdu) + iso.Mti.String() + bitmapString + elementsStr
-	} else {
-		str = iso.Mti.String() + bitmapString + elementsStr
+	return iso.packHeader(header + elementsStr)
+}
+
+// packHeader puts the header in front of a packed message
+func (iso *IsoStruct) packHeader(msg string) (string, error) {
+	if iso.Header == nil {
+		return msg, nil
 	}
-	return str, nil
+	header, err := iso.Header.Pack(len(msg))
+	if err != nil {
+		return "", err
+	}
+	return string(header) + msg, nil
 }
 
 // AddMTI adds the provided iso8583 MTI into the current struct
@@ -88,67 +136,194 @@ func (iso *IsoStruct) AddMTI(data string) error {
 	return nil
 }
 
+// Validate checks the mti and every field against the spec along with
+// the fields the spec expects of the message, and reports all problems
+// at once, nil when there are none
+func (iso *IsoStruct) Validate() ValidationErrors {
+	var errs ValidationErrors
+	_, err := MtiValidator(iso.Mti)
+	if err != nil {
+		errs = append(errs, ValidationError{Label: iso.Spec.fields[0].Label, Rule: RuleMTI, Value: iso.Mti.String(), message: err.Error()})
+	}
+	for _, field := range iso.fieldNumbers() {
+		if invalid := iso.Spec.validateValue(field, iso.Elements.elements[field]); invalid != nil {
+			errs = append(errs, *invalid)
+		}
+	}
+	return append(errs, iso.checkPresence()...)
+}
+
+// fieldNumbers returns the fields present in the message in order
+func (iso *IsoStruct) fieldNumbers() []int64 {
+	fields := make([]int64, 0, len(iso.Elements.elements))
+	for field := range iso.Elements.elements {
+		fields = append(fields, field)
+	}
+	sort.Slice(fields, func(i, j int) bool { return fields[i] < fields[j] })
+	return fields
+}
+
 // AddField adds the provided iso8583 field into the current struct
 // also updates the bitmap in the process
 func (iso *IsoStruct) AddField(field int64, data string) error {
 	if field < 2 || field > int64(len(iso.Bitmap)) {
 		return fmt.Errorf("expected field to be between %d and %d found %d instead", 2, len(iso.Bitmap), field)
 	}
+	if isTertiaryIndicator(iso.Bitmap, int(field)) {
+		return fmt.Errorf("field %d flags the tertiary bitmap and can not carry data", field)
+	}
+	if !iso.LazyValidation {
+		if invalid := iso.Spec.validateValue(field, data); invalid != nil {
+			return *invalid
+		}
+	}
 	iso.Bitmap[field-1] = 1
 	iso.Elements.elements[field] = data
+	delete(iso.subfields, field)
+	delete(iso.chipData, field)
 	return nil
 }
 
+// GetField returns the value of a field the way it is held in the struct,
+// fields packed as binary are held as hex
+func (iso *IsoStruct) GetField(field int64) (string, bool) {
+	data, ok := iso.Elements.elements[field]
+	return data, ok
+}
+
+// GetFieldBytes returns the value of a field as bytes,
+// fields held as hex are decoded back into the bytes they stand for
+func (iso *IsoStruct) GetFieldBytes(field int64) ([]byte, error) {
+	data, ok := iso.Elements.elements[field]
+	if !ok {
+		return nil, fmt.Errorf("field %d is not present", field)
+	}
+	if holdsHex(iso.Spec.fields[int(field)]) {
+		return hex.DecodeString(data)
+	}
+	return []byte(data), nil
+}
+
+// AddFieldBytes adds the provided iso8583 field from its bytes,
+// fields held as hex are encoded before being stored
+func (iso *IsoStruct) AddFieldBytes(field int64, data []byte) error {
+	if holdsHex(iso.Spec.fields[int(field)]) {
+		return iso.AddField(field, hex.EncodeToString(data))
+	}
+	return iso.AddField(field, string(data))
+}
+
+// RemoveField removes the provided iso8583 field from the current struct
+// also updates the bitmap in the process
 func (iso *IsoStruct) RemoveField(field int64) error {
 	if field < 2 || field > int64(len(iso.Bitmap)) {
 		return fmt.Errorf("expected field to be between %d and %d found %d instead", 2, len(iso.Bitmap), field)
 	}
+	if isTertiaryIndicator(iso.Bitmap, int(field)) {
+		return fmt.Errorf("field %d flags the tertiary bitmap and can not be removed", field)
+	}
 	iso.Bitmap[field-1] = 0
 	delete(iso.Elements.elements, field)
+	delete(iso.subfields, field)
+	delete(iso.chipData, field)
+	return nil
+}
+
+// Unpack parses an iso8583 message into the current struct,
+// a header is expected in front of the message whenever iso.Header is set
+// just like Pack writes one
+func (iso *IsoStruct) Unpack(data []byte) error {
+	q, err := iso.Parse(string(data))
+	if err != nil {
+		return err
+	}
+	*iso = q
 	return nil
 }
 
-// Parse parses an iso8583 string
-func (iso *IsoStruct) Parse(i string, useTpdu bool) (IsoStruct, error) {
+// UnmarshalBinary implements encoding.BinaryUnmarshaler using Unpack
+func (iso *IsoStruct) UnmarshalBinary(data []byte) error {
+	return iso.Unpack(data)
+}
+
+// Parse parses an iso8583 string, a header of the same kind as
+// iso.Header is read off the front of it first
+func (iso *IsoStruct) Parse(i string) (IsoStruct, error) {
+	q, _, err := iso.parse(i)
+	return q, err
+}
+
+// parse parses one message off the front of i and returns whatever
+// follows it, also when the message turns out to be invalid
+func (iso *IsoStruct) parse(i string) (IsoStruct, string, error) {
+	q, rest, err := iso.unpack(i)
+	if err != nil {
+		return q, "", err
+	}
+	return q, rest, q.check()
+}
+
+// unpack splits one message off the front of i into its fields
+// without checking their values
+func (iso *IsoStruct) unpack(i string) (IsoStruct, string, error) {
 	var q IsoStruct
 	spec := iso.Spec
-	var msg string
-	var tpdu []byte
+	msg := i
 
-	if useTpdu {
+	var header Header
+	if iso.Header != nil {
+		var rest []byte
 		var err error
-		tpdu, msg, err = extractTpdu(i)
+		header, rest, err = iso.Header.Unpack([]byte(i))
 		if err != nil {
-			fmt.Println(err.Error())
+			return q, "", err
 		}
-
-		iso.Tpdu = tpdu
-
-	} else {
-		msg = i
+		msg = string(rest)
 	}
-	fmt.Printf("tpdu: %v\n", iso.Tpdu)
-
-	mti, rest := extractMTI(msg, spec.fields[0].HeaderHex)
-	bitmap, elementString, err := extractBitmap(rest, spec.fields[1].HeaderHex)
 
+	mti, rest, err := extractMTI(msg, spec.fields[0])
+	if err != nil {
+		return q, "", err
+	}
+	tertiary := len(iso.Bitmap) == 192 || spec.hasTertiaryFields()
+	bitmap, elementString, err := extractBitmap(rest, spec.fields[1], tertiary)
 	if err != nil {
-		return q, err
+		return q, "", err
 	}
 
 	// validat the mti
 	_, err = MtiValidator(mti)
 	if err != nil {
-		return q, err
+		return q, "", err
 	}
 
-	elements, err := unpackElements(bitmap, elementString, spec)
+	elements, rest, err := unpackElements(bitmap, elementString, spec)
 	if err != nil {
-		return q, err
+		return q, "", err
 	}
 
-	q = IsoStruct{Spec: spec, Mti: mti, Bitmap: bitmap, Elements: elements, Tpdu: tpdu}
-	return q, nil
+	q = IsoStruct{Spec: spec, Mti: mti, Bitmap: bitmap, Elements: elements, Header: header, LazyValidation: iso.LazyValidation}
+	return q, rest, nil
+}
+
+// check validates a message that was just unpacked unless validation
+// is lazy, fields made of subfields are split right away so malformed
+// ones are caught here
+func (iso *IsoStruct) check() error {
+	if !iso.LazyValidation {
+		if errs := iso.Validate(); errs != nil {
+			return errs
+		}
+	}
+	for _, field := range iso.fieldNumbers() {
+		if len(iso.Spec.fields[int(field)].Subfields) > 0 {
+			_, err := iso.loadSubfields(field)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
 
 func (iso *IsoStruct) packElements() (string, error) {
@@ -158,149 +333,167 @@ func (iso *IsoStruct) packElements() (string, error) {
 	elementsSpec := iso.Spec
 
 	for index := 1; index < len(bitmap); index++ { // index 0 of bitmap isn't need here
-		if bitmap[index] == 1 { // if the field is present
+		if bitmap[index] == 1 && !isTertiaryIndicator(bitmap, index+1) { // if the field is present
 			field := int64(index + 1)
 			fieldDescription := elementsSpec.fields[int(field)]
-			if fieldDescription.LenType == "fixed" {
-
-				if fieldDescription.HeaderHex {
-					strtemp := elementsMap[field]
-					strByte, _ := hex.DecodeString(strtemp)
-					str = str + string(strByte)
-				} else {
-					str = str + elementsMap[field]
-				}
-
-			} else {
-				lengthType, err := getVariableLengthFromString(fieldDescription.LenType)
-				if err != nil {
-					return str, err
-				}
-
-				fieldLen := len(elementsMap[field])
-				if fieldDescription.Contain != "string" {
-					fieldLen = fieldLen * 2
-				}
-
-				if fieldDescription.HeaderHex {
-					actualLength := fieldLen / 2
-
-					lenVar := int(lengthType)
-					if lenVar == 3 {
-						lenVar = 4
-					}
-
-					paddedLength := leftPad(strconv.Itoa(actualLength), lenVar, "0")
-
-					strtemp := (paddedLength + elementsMap[field])
-					strByte, _ := hex.DecodeString(strtemp)
-					str = str + string(strByte)
-				} else {
-					str = str + elementsMap[field]
-				}
-
+			data, err := packField(fieldDescription, strconv.Itoa(index+1), elementsMap[field])
+			if err != nil {
+				return str, err
 			}
+			str = str + data
 		}
 	}
 	return str, nil
 }
 
-func extractTpdu(rest string) ([]byte, string, error) {
+// packField packs a single field (or subfield) value as described
+// by its field description, length prefix included
+func packField(fieldDescription FieldDescription, field string, value string) (string, error) {
+	if fieldDescription.LenType == "fixed" {
+		value, err := padField(fieldDescription, value)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %s", field, err.Error())
+		}
+		var data string
+		if isTrack2(fieldDescription) {
+			data, err = packTrack2(fieldDescription, value)
+			if err != nil {
+				return "", fmt.Errorf("field %s: %s", field, err.Error())
+			}
+		} else if isBCD(fieldDescription) {
+			data, err = packBCD(value, bcdPadNibble(fieldDescription), fieldDescription.BcdJustify)
+			if err != nil {
+				return "", fmt.Errorf("field %s: %s", field, err.Error())
+			}
+		} else if fieldDescription.HeaderHex {
+			strByte, _ := hex.DecodeString(value)
+			data = string(strByte)
+		} else {
+			data, err = encodeText(fieldDescription.Encoding, value)
+			if err != nil {
+				return "", fmt.Errorf("spec error: field %s: %s", field, err.Error())
+			}
+		}
+		return data, nil
+	}
 
-	if len(rest) < 5 {
-		return nil, "", fmt.Errorf("could not slice %d string of %d\n", len(rest), 5)
+	fieldLen := len(value)
+	if lengthInBytes(fieldDescription) {
+		fieldLen = fieldLen / 2
+	}
+	if valueLen := valueLength(fieldDescription, value); fieldDescription.MaxLen > 0 && valueLen > fieldDescription.MaxLen {
+		return "", fmt.Errorf("field %s: value of length %d exceeds max length %d", field, valueLen, fieldDescription.MaxLen)
 	}
 
-	frontHex := rest[0:5]
-	tpdu := []byte(frontHex)
-	msg := rest[5:len(rest)]
+	var data string
+	var err error
+	if isTrack2(fieldDescription) {
+		data, err = packTrack2(fieldDescription, value)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %s", field, err.Error())
+		}
+	} else if isBCD(fieldDescription) {
+		data, err = packBCD(value, bcdPadNibble(fieldDescription), fieldDescription.BcdJustify)
+		if err != nil {
+			return "", fmt.Errorf("field %s: %s", field, err.Error())
+		}
+	} else if fieldDescription.HeaderHex {
+		strByte, _ := hex.DecodeString(value)
+		data = string(strByte)
+		if padsChipData(fieldDescription) && len(data)%2 != 0 {
+			data = data + "\x00"
+		}
+	} else {
+		data, err = encodeText(fieldDescription.Encoding, value)
+		if err != nil {
+			return "", fmt.Errorf("spec error: field %s: %s", field, err.Error())
+		}
+	}
 
-	return tpdu, msg, nil
+	lengthStr, err := encodeLength(fieldDescription, fieldLen)
+	if err != nil {
+		return "", fmt.Errorf("field %s: %s", field, err.Error())
+	}
+	return lengthStr + data, nil
 }
 
 // extractMTI extracts the mti from an iso8583 string
-func extractMTI(str string, isHex bool) (MtiType, string) {
+func extractMTI(str string, fieldDescription FieldDescription) (MtiType, string, error) {
 
-	if !isHex {
+	if !fieldDescription.HeaderHex {
 
 		if len(str) < 4 {
-			return MtiType{}, ""
+			return MtiType{}, "", &TruncatedError{Field: "0", Need: 4, Have: len(str)}
 		}
 
-		mti := str[0:4]
+		mti, err := decodeText(fieldDescription.Encoding, str[0:4])
+		if err != nil {
+			return MtiType{}, "", fmt.Errorf("spec error: field 0: %s", err.Error())
+		}
 		rest := str[4:len(str)]
 
-		return MtiType{mti: mti}, rest
+		return MtiType{mti: mti}, rest, nil
 	} else {
 
 		if len(str) < 2 {
-			return MtiType{}, ""
+			return MtiType{}, "", &TruncatedError{Field: "0", Need: 2, Have: len(str)}
 		}
 
 		mti := hex.EncodeToString([]byte(str[0:2]))
 		rest := str[2:len(str)]
 
-		return MtiType{mti: string(mti)}, rest
+		return MtiType{mti: string(mti)}, rest, nil
 	}
 }
 
-func extractBitmap(rest string, isHex bool) ([]int64, string, error) {
+// extractBitmap reads the primary bitmap and the ones it flags, field 65
+// only flags a tertiary bitmap when tertiary bitmaps are in use and is
+// data otherwise
+func extractBitmap(rest string, fieldDescription FieldDescription, tertiary bool) ([]int64, string, error) {
 	var bitmap []int64
 	var elementsString string
-	var inDec []byte
 	var err error
+	isHex := fieldDescription.HeaderHex
 
-	if len(rest) < 1 {
-		return bitmap, elementsString, fmt.Errorf("bitmap length = 0, no bitmap to be processed")
+	// every bitmap is 64 bits, 16 hex characters or 8 bytes when packed
+	bitmapLength := 16
+	if isHex {
+		bitmapLength = 8
 	}
 
-	if !isHex {
-		// remove first two characters
-		frontHex := rest[0:2]
-		//fmt.Println(frontHex)
-		inDec, err = hex.DecodeString(frontHex)
-		if err != nil {
-			return bitmap, elementsString, err
+	// the first bit of the primary bitmap says whether a secondary bitmap follows,
+	// the first bit of the secondary (field 65) says whether a tertiary one does
+	bitmaps := 2
+	if tertiary {
+		bitmaps = 3
+	}
+	var bitmapHexString string
+	for count := 1; count <= bitmaps; count++ {
+		if len(rest) < count*bitmapLength {
+			return bitmap, elementsString, &TruncatedError{Field: "1", Need: count * bitmapLength, Have: len(rest)}
 		}
-	} else {
-		// remove first characters
-		frontHex := hex.EncodeToString([]byte(rest[0:1]))
-		//fmt.Println(frontHex)
-		inDec, err = hex.DecodeString(string(frontHex))
+
+		var bitmapHex string
+		if !isHex {
+			bitmapHex, err = decodeText(fieldDescription.Encoding, rest[(count-1)*bitmapLength:count*bitmapLength])
+			if err != nil {
+				return bitmap, elementsString, fmt.Errorf("spec error: field 1: %s", err.Error())
+			}
+		} else {
+			bitmapHex = hex.EncodeToString([]byte(rest[(count-1)*bitmapLength : count*bitmapLength]))
+		}
+		bitmapHexString = bitmapHexString + bitmapHex
+
+		inDec, err := hex.DecodeString(bitmapHex[0:2])
 		if err != nil {
 			return bitmap, elementsString, err
 		}
+		if inDec[0]&0x80 == 0 || count == bitmaps {
+			elementsString = rest[count*bitmapLength:]
+			break
+		}
 	}
 
-	inBinary := fmt.Sprintf("%8b", inDec[0])
-	compare := "1"
-	var bitmapHexLength int
-
-	// if the first bit of the bitmap is 1,
-	// it means a secondary bitmap exist hence its a 128 bit bitmap (hex length 32)
-	if inBinary[0] == compare[0] { // don't why I did it like this
-		// secondary bitmap exists
-		bitmapHexLength = 32
-	} else {
-		// only primary bitmap is there
-		// 64 bit bitmap (hex length 16)
-		bitmapHexLength = 16
-	}
-
-	if isHex {
-		bitmapHexLength = bitmapHexLength / 2
-	}
-
-	var bitmapHexString string
-	if !isHex {
-		bitmapHexString = rest[0:bitmapHexLength]
-	} else {
-		bitmapHexByte := hex.EncodeToString([]byte(rest[0:bitmapHexLength]))
-		bitmapHexString = string(bitmapHexByte)
-	}
-
-	elementsString = rest[bitmapHexLength:len(rest)]
-
 	bitmap, err = HexToBitmapArray(bitmapHexString)
 	if err != nil {
 		return bitmap, elementsString, err
@@ -319,64 +512,202 @@ func getVariableLengthFromString(str string) (int64, error) {
 	if str == "llllvar" {
 		return 4, nil
 	}
+	if str == "lllllvar" {
+		return 5, nil
+	}
+	if str == "llllllvar" {
+		return 6, nil
+	}
 
 	return num, fmt.Errorf("%s is an invalid LenType", str)
 }
 
+// getBinaryLengthFromString returns the number of bytes taken by
+// a binary length prefix, ok is false for any other LenType
+func getBinaryLengthFromString(str string) (size int, ok bool) {
+	if str == "lbin" {
+		return 1, true
+	}
+	if str == "llbin" {
+		return 2, true
+	}
+	return 0, false
+}
+
+// valueLength returns the length of a variable field value in the units
+// MaxLen is given in, bytes for fields held as hex and characters otherwise
+func valueLength(fieldDescription FieldDescription, data string) int {
+	if holdsHex(fieldDescription) {
+		return len(data) / 2
+	}
+	return len(data)
+}
+
+// lengthInBytes reports whether the length prefix of a field kept as hex
+// counts bytes rather than hex digits, strings, chip tags and binary prefixes do
+func lengthInBytes(fieldDescription FieldDescription) bool {
+	if !holdsHex(fieldDescription) {
+		return false
+	}
+	_, binary := getBinaryLengthFromString(fieldDescription.LenType)
+	return binary || fieldDescription.Contain == "string" || fieldDescription.Contain == "chip-tag"
+}
+
+// padsChipData reports whether a chip-tag field is read in whole pairs of
+// bytes, odd lengths padded with 00, as it is behind a digit length prefix,
+// binary length prefixes count the bytes on the wire exactly
+func padsChipData(fieldDescription FieldDescription) bool {
+	_, binary := getBinaryLengthFromString(fieldDescription.LenType)
+	return fieldDescription.Contain == "chip-tag" && fieldDescription.HeaderHex && !binary
+}
+
+// chipDigits is the number of hex digits a padded chip-tag field
+// of length bytes takes on the wire
+func chipDigits(length int) int {
+	return (length + 1) / 2 * 4
+}
+
+// encodeLength renders the length of a variable length field
+// as the prefix that goes in front of it on the wire
+func encodeLength(fieldDescription FieldDescription, length int) (string, error) {
+	if size, ok := getBinaryLengthFromString(fieldDescription.LenType); ok {
+		if length >= 1<<uint(8*size) {
+			return "", fmt.Errorf("length %d does not fit in a %s prefix", length, fieldDescription.LenType)
+		}
+		prefix := make([]byte, size)
+		for i := size - 1; i >= 0; i-- {
+			prefix[i] = byte(length)
+			length = length >> 8
+		}
+		return string(prefix), nil
+	}
+
+	lengthType, err := getVariableLengthFromString(fieldDescription.LenType)
+	if err != nil {
+		return "", err
+	}
+	paddedLength := leftPad(strconv.Itoa(length), int(lengthType), "0")
+	if len(paddedLength) > int(lengthType) {
+		return "", fmt.Errorf("length %d does not fit in a %s prefix", length, fieldDescription.LenType)
+	}
+
+	if fieldDescription.HeaderHex {
+		return packBCD(paddedLength, "0", "right")
+	}
+	return encodeText(fieldDescription.Encoding, paddedLength)
+}
+
+// decodeLength reads the length prefix of a variable length field
+// and returns it along with the rest of the string
+func decodeLength(fieldDescription FieldDescription, str string) (int64, string, error) {
+	if size, ok := getBinaryLengthFromString(fieldDescription.LenType); ok {
+		if len(str) < size {
+			return 0, str, &TruncatedError{Need: size, Have: len(str)}
+		}
+		var length int64
+		for i := 0; i < size; i++ {
+			length = length<<8 | int64(str[i])
+		}
+		return length, str[size:], nil
+	}
+
+	length, err := getVariableLengthFromString(fieldDescription.LenType)
+	if err != nil {
+		return 0, str, err
+	}
+
+	var fieldLength string
+	if fieldDescription.HeaderHex {
+		digits := int(length)
+		length = (length + 1) / 2
+		if int64(len(str)) < length {
+			return 0, str, &TruncatedError{Need: int(length), Have: len(str)}
+		}
+		fieldLength = unpackBCD(str[0:length], digits, "right")
+	} else {
+		if int64(len(str)) < length {
+			return 0, str, &TruncatedError{Need: int(length), Have: len(str)}
+		}
+		fieldLength, err = decodeText(fieldDescription.Encoding, str[0:length]) // get the embedded length
+		if err != nil {
+			return 0, str, err
+		}
+	}
+
+	fieldLengthInt, err := strconv.ParseInt(fieldLength, 10, 64)
+	if err != nil {
+		return 0, str, err
+	}
+	return fieldLengthInt, str[length:], nil
+}
+
 func extractFieldFromElements(spec Spec, field int, str string) (string, string, error) {
+	return extractField(spec.fields[field], strconv.Itoa(field), str)
+}
+
+// extractField reads a single field (or subfield) off the front of str
+// as described by its field description
+func extractField(fieldDescription FieldDescription, field string, str string) (string, string, error) {
 	var extractedField, substr string
-	fieldDescription := spec.fields[int(field)]
 
 	if fieldDescription.LenType == "fixed" {
 
+		length := fieldDescription.MaxLen
+		if padsChipData(fieldDescription) {
+			length = chipDigits(length)
+		}
 		var err error
-		extractedField, substr, err = getFieldValue(fieldDescription.HeaderHex, fieldDescription.MaxLen, fieldDescription.Contain, str)
+		extractedField, substr, err = getFieldValue(fieldDescription.HeaderHex, length, str)
 		if err != nil {
-			return extractedField, substr, fmt.Errorf("spec error: field %d: %s", field, err.Error())
+			return extractedField, substr, fieldError(field, err)
+		}
+		if isBCD(fieldDescription) {
+			extractedField = trimBCDPad(extractedField, fieldDescription.MaxLen, fieldDescription.BcdJustify)
+		} else if isTrack2(fieldDescription) && fieldDescription.HeaderHex {
+			extractedField = unpackTrack2(fieldDescription, extractedField, fieldDescription.MaxLen)
 		}
 
 	} else {
 		// varianle length fields have their lengths embedded into the string
-		length, err := getVariableLengthFromString(fieldDescription.LenType)
+		fieldLengthInt, tempSubstr, err := decodeLength(fieldDescription, str)
 		if err != nil {
-			return extractedField, substr, fmt.Errorf("spec error: field %d: %s", field, err.Error())
+			return extractedField, substr, fieldError(field, err)
 		}
 
-		var fieldLength string
-		if fieldDescription.HeaderHex {
-			if length%2 != 0 {
-				length = (length + 1) / 2
-			} else {
-				length = length / 2
-			}
-
-			fieldLength1 := hex.EncodeToString([]byte(str[0:length]))
-			fieldLength = string(fieldLength1)
-
-		} else {
-			fieldLength = str[0:length] // get the embedded length
+		length := int(fieldLengthInt)
+		if padsChipData(fieldDescription) {
+			length = chipDigits(length)
+		} else if lengthInBytes(fieldDescription) {
+			length = length * 2
 		}
-		tempSubstr := str[length:len(str)] // get the string with the length removed
 
-		fieldLengthInt, err := strconv.ParseInt(fieldLength, 10, 64)
+		extractedField, substr, err = getFieldValue(fieldDescription.HeaderHex, length, tempSubstr)
 		if err != nil {
-			return extractedField, substr, err
+			return extractedField, substr, fieldError(field, err)
 		}
-
-		if fieldDescription.Contain == "string" {
-			fieldLengthInt = fieldLengthInt * 2
+		if padsChipData(fieldDescription) {
+			// the prefix leaves out the 00 an odd length is padded with
+			extractedField = extractedField[:fieldLengthInt*2]
+		}
+		if isBCD(fieldDescription) {
+			extractedField = trimBCDPad(extractedField, int(fieldLengthInt), fieldDescription.BcdJustify)
+		} else if isTrack2(fieldDescription) && fieldDescription.HeaderHex {
+			extractedField = unpackTrack2(fieldDescription, extractedField, int(fieldLengthInt))
 		}
+	}
 
-		extractedField, substr, err = getFieldValue(fieldDescription.HeaderHex, int(fieldLengthInt), fieldDescription.Contain, tempSubstr)
+	if !fieldDescription.HeaderHex {
+		var err error
+		extractedField, err = decodeText(fieldDescription.Encoding, extractedField)
 		if err != nil {
-			return extractedField, substr, err
+			return extractedField, substr, fmt.Errorf("spec error: field %s: %s", field, err.Error())
 		}
 	}
 
 	return extractedField, substr, nil
 }
 
-func getFieldValue(headerHex bool, maxLen int, contain string, str string) (extractedField string, substr string, err error) {
+func getFieldValue(headerHex bool, maxLen int, str string) (extractedField string, substr string, err error) {
 	if headerHex {
 		var length int
 		if maxLen%2 != 0 {
@@ -385,13 +716,8 @@ func getFieldValue(headerHex bool, maxLen int, contain string, str string) (extr
 			length = maxLen / 2
 		}
 
-		//if xxxvar chip, length di x 2
-		if contain == "chip-tag" {
-			length = length * 2
-		}
-
 		if len(str) < length {
-			return extractedField, substr, fmt.Errorf("could not slice %d string of %d\n", len(str), length)
+			return extractedField, substr, &TruncatedError{Need: length, Have: len(str)}
 		}
 
 		extractedFieldTemp := str[0:length]
@@ -401,7 +727,7 @@ func getFieldValue(headerHex bool, maxLen int, contain string, str string) (extr
 		substr = str[length:len(str)]
 	} else {
 		if len(str) < maxLen {
-			return extractedField, substr, fmt.Errorf("could not slice %d string of %d\n", len(str), maxLen)
+			return extractedField, substr, &TruncatedError{Need: maxLen, Have: len(str)}
 		}
 
 		extractedField = str[0:maxLen]
@@ -411,7 +737,7 @@ func getFieldValue(headerHex bool, maxLen int, contain string, str string) (extr
 	return extractedField, substr, nil
 }
 
-func unpackElements(bitmap []int64, elements string, spec Spec) (ElementsType, error) {
+func unpackElements(bitmap []int64, elements string, spec Spec) (ElementsType, string, error) {
 	var elem ElementsType
 	var m = make(map[int64]string)
 	currentString := elements
@@ -419,47 +745,89 @@ func unpackElements(bitmap []int64, elements string, spec Spec) (ElementsType, e
 	// we therefore start with the second bit (index 1) which is field (2)
 	for index := 1; index < len(bitmap); index++ {
 		bit := bitmap[index]
-		if bit == 1 { // field is present
+		if bit == 1 && !isTertiaryIndicator(bitmap, index+1) { // field is present
 			field := index + 1 // adjust to account for the fact that arrays start at 0
 			extractedField, substr, err := extractFieldFromElements(spec, field, currentString)
 			if err == nil {
 				m[int64(field)] = extractedField
 				currentString = substr
 			} else {
-				return elem, err
+				return elem, currentString, err
 			}
 		}
 	}
 
 	elem = ElementsType{elements: m}
-	return elem, nil
+	return elem, currentString, nil
+}
+
+// holdsHex reports whether the value of a field is kept as a hex string
+// of the bytes that go on the wire
+func holdsHex(fieldDescription FieldDescription) bool {
+	return fieldDescription.HeaderHex && !isBCD(fieldDescription) && !isTrack2(fieldDescription)
+}
+
+// isTertiaryIndicator reports whether field is field 65 of a message
+// built or parsed with a tertiary bitmap, in any other message field 65
+// is data
+func isTertiaryIndicator(bitmap []int64, field int) bool {
+	return field == 65 && len(bitmap) == 192
 }
 
 // NewISOStruct creates a new IsoStruct
 // based on the content of the specfile provided
 func NewISOStruct(filename string, secondaryBitmap bool) IsoStruct {
+	if secondaryBitmap == true {
+		return NewISOStructWithBitmaps(filename, 2)
+	}
+	return NewISOStructWithBitmaps(filename, 1)
+}
+
+// NewISOStructWithBitmaps creates a new IsoStruct with the given
+// number of bitmaps (1, 2 or 3) allowing fields up to 64, 128 or 192
+func NewISOStructWithBitmaps(filename string, bitmaps int) IsoStruct {
+	spec, err := SpecFromFile(filename)
+	if err != nil {
+		panic(err) // we panic because we don't want to do anything without a valid specfile
+	}
+	return NewISOStructFromSpecWithBitmaps(spec, bitmaps)
+}
+
+// NewISOStructFromSpec creates a new IsoStruct from a spec
+// that is already loaded, so it can be shared by every message
+func NewISOStructFromSpec(spec Spec, secondaryBitmap bool) IsoStruct {
+	if secondaryBitmap == true {
+		return NewISOStructFromSpecWithBitmaps(spec, 2)
+	}
+	return NewISOStructFromSpecWithBitmaps(spec, 1)
+}
+
+// NewISOStructFromSpecWithBitmaps creates a new IsoStruct from a loaded spec
+// with the given number of bitmaps (1, 2 or 3)
+func NewISOStructFromSpecWithBitmaps(spec Spec, bitmaps int) IsoStruct {
 	var iso IsoStruct
 	var bitmap []int64
 	mti := MtiType{mti: ""}
 
-	if secondaryBitmap == true {
-		bitmap = make([]int64, 128)
+	if bitmaps < 1 || bitmaps > 3 {
+		panic(fmt.Errorf("expected 1, 2 or 3 bitmaps found %d instead", bitmaps))
+	}
+	bitmap = make([]int64, 64*bitmaps)
+	if bitmaps > 1 {
 		bitmap[0] = 1
-	} else {
-		bitmap = make([]int64, 64)
+	}
+	if bitmaps > 2 {
+		bitmap[64] = 1
 	}
 
 	emap := make(map[int64]string)
 	elements := ElementsType{elements: emap}
-	spec, err := SpecFromFile(filename)
+
+	header, err := spec.Header()
 	if err != nil {
-		panic(err) // we panic because we don't want to do anything without a valid specfile
+		panic(err)
 	}
 
-	var tpdu []byte
-	tpdu = make([]byte, 5)
-	fmt.Printf("tpdu: %#v", tpdu)
-
-	iso = IsoStruct{Spec: spec, Mti: mti, Bitmap: bitmap, Elements: elements, Tpdu: tpdu}
+	iso = IsoStruct{Spec: spec, Mti: mti, Bitmap: bitmap, Elements: elements, Header: header}
 	return iso
 }
@@ -1,13 +1,29 @@
 package iso8583
 
 import (
+	"bytes"
+	"encoding"
 	"encoding/hex"
 	"fmt"
+	"strings"
 	"testing"
-
-	"github.com/harda/iso8583"
 )
 
+// buildSpec puts a test spec together with the spec builder,
+// so it is checked the way a spec file is
+func buildSpec(t *testing.T, fields map[int]FieldDescription) Spec {
+	t.Helper()
+	builder := NewSpecBuilder()
+	for field, fieldDescription := range fields {
+		builder.Field(field, fieldDescription)
+	}
+	spec, err := builder.Build()
+	if err != nil {
+		t.Fatalf("failed to build spec: %s", err.Error())
+	}
+	return spec
+}
+
 func TestISOParseByte(t *testing.T) {
 	// MTI = 0200
 	// Field (3) = 000010
@@ -47,7 +63,7 @@ func TestISOParseByte(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -82,7 +98,7 @@ func TestISOParseInt(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -110,7 +126,7 @@ func TestISOParse(t *testing.T) {
 	// Field (49) = 840
 	isomsg := "02003220000000808000000010000000001500120604120000000112340001840"
 	isostruct := NewISOStruct("spec1987.yml", true)
-	parsed, err := isostruct.Parse(isomsg, false)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -155,7 +171,7 @@ func TestEmptyPos(t *testing.T) {
 		t.Errorf("Empty generates invalid MTI")
 	}
 	one.AddMTI("0200")
-	one.Tpdu = []byte{96, 0, 50, 0, 0}
+	one.Header = Tpdu{ID: 0x60, Destination: 0x0032}
 	one.AddField(3, "000010")
 	one.AddField(4, "000000001500")
 	one.AddField(7, "1206041200")
@@ -163,7 +179,7 @@ func TestEmptyPos(t *testing.T) {
 	one.AddField(41, "12340001")
 	one.AddField(49, "840")
 
-	dataByte, _ := hex.DecodeString("0200322000000080800000001000000000150031323036303431323030000001")
+	dataByte, _ := hex.DecodeString("6000320000" + "0200322000000080800000001000000000150031323036303431323030000001")
 	expected := "12340001840"
 	expected = string(dataByte) + expected
 
@@ -179,7 +195,7 @@ func TestMessageFromSample1(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -192,10 +208,10 @@ func TestMessageFromSample1(t *testing.T) {
 	}
 	fmt.Println(isomsgUnpacked)
 
-	one := iso8583.NewISOStruct("spec1987pos.yml", false)
+	one := NewISOStruct("spec1987pos.yml", false)
 
 	one.AddMTI("0800")
-	one.Tpdu = []byte{96, 0, 24, 0, 0}
+	one.Header = Tpdu{ID: 0x60, Destination: 0x0018}
 	one.AddField(3, "920000")
 	one.AddField(11, "000299")
 	one.AddField(24, "0018")
@@ -216,10 +232,10 @@ func TestMessageFromSample1(t *testing.T) {
 
 func TestMessageFromSample2(t *testing.T) {
 
-	// one := iso8583.NewISOStruct("spec1987pos.yml", false)
+	// one := NewISOStruct("spec1987pos.yml", false)
 
 	// one.AddMTI("0800")
-	// one.Tpdu = []byte{96, 0, 24, 0, 0}
+	// one.Header = Tpdu{ID: 0x60, Destination: 0x0018}
 	// one.AddField(1, "2020010000800004")
 	// one.AddField(3, "920000")
 	// one.AddField(11, "000299")
@@ -236,7 +252,7 @@ func TestMessageFromSample2(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos2.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -266,7 +282,7 @@ func TestMessageFromSample3(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos3.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -300,7 +316,7 @@ func TestMessageFromSampleLogonResponse(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos3.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -329,7 +345,7 @@ func TestMessageFromSample4a(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos3.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -359,7 +375,7 @@ func TestMessageFromSample4(t *testing.T) {
 
 	isomsg := string(isobyte)
 	isostruct := NewISOStruct("spec1987pos.yml", true)
-	parsed, err := isostruct.Parse(isomsg, true)
+	parsed, err := isostruct.Parse(isomsg)
 	if err != nil {
 		fmt.Println(err)
 		t.Errorf("parse iso message failed")
@@ -372,25 +388,25 @@ func TestMessageFromSample4(t *testing.T) {
 	}
 	fmt.Println(isomsgUnpacked)
 
-	one := iso8583.NewISOStruct("spec1987pos.yml", false)
+	one := NewISOStruct("spec1987pos.yml", false)
 
-	one.AddMTI("0800")
-	one.Tpdu = []byte{96, 0, 24, 0, 0}
+	one.AddMTI("0200")
+	one.Header = Tpdu{ID: 0x60, Destination: 0x0009}
 	one.AddField(3, "000000")
 	one.AddField(4, "000000000300")
 	one.AddField(11, "000359")
-	one.AddField(22, "0051")
-	one.AddField(23, "0001")
-	one.AddField(24, "0008")
+	one.AddField(22, "051")
+	one.AddField(23, "001")
+	one.AddField(24, "008")
 	one.AddField(25, "00")
-	one.AddField(35, "5304872000000848d230622600000036200000")
+	one.AddField(35, "5304872000000848=23062260000003620000")
 	one.AddField(41, "77000033")
 	one.AddField(42, "000008770000033")
 	one.AddField(52, "f9ff7fa34d1778a0")
-	one.AddField(55, "5f2a020360820274008407a0000006021010950508000488009a032103039c01009f02060000000003009f03060000000000009f090201009f101c9f01a00000000088692c8c00000000000000000000000000000000009f1a0203609f1e0835313838343138349f26089839c8f4f17310739f2701809f3303e0f8c89f34030200009f3501229f360203a19f37046669a26b9f4104000003599f53015200")
+	one.AddField(55, "5f2a020360820274008407a0000006021010950508000488009a032103039c01009f02060000000003009f03060000000000009f090201009f101c9f01a00000000088692c8c00000000000000000000000000000000009f1a0203609f1e0835313838343138349f26089839c8f4f17310739f2701809f3303e0f8c89f34030200009f3501229f360203a19f37046669a26b9f4104000003599f530152")
 	one.AddField(58, "df01083531383834313834")
-	one.AddField(62, "48544c45303331303031303031373730303030333330303030303030378ca64de98ca64de9")
-	one.AddField(64, "0000000000000000")
+	one.AddField(62, "343030303230")
+	one.AddField(64, "\x00\x00\x00\x00\x00\x00\x00\x00")
 
 	oneString, _ := one.ToString()
 
@@ -402,9 +418,239 @@ func TestMessageFromSample4(t *testing.T) {
 	lenbyte[1] = byte(len(isomsg))
 	fmt.Printf("len of sample 4: %#v\n", lenbyte)
 
-	if isomsgUnpacked != oneString {
-		t.Errorf("%s should be %s", isomsgUnpacked, oneString)
+	if isomsgUnpacked != isomsg {
+		t.Errorf("%x should be %x", isomsgUnpacked, isomsg)
+	}
+	if oneString != isomsg {
+		t.Errorf("%x should be %x", oneString, isomsg)
 	}
 	fmt.Printf("visionet sample 4: %#v, %#v\n%#v", parsed.Mti, parsed.Bitmap, parsed.Elements)
 	// fmt.Println("-------------")
 }
+
+func TestBinaryLengthPrefix(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:  {ContentType: "n", LenType: "fixed", MaxLen: 4},
+		1:  {ContentType: "b", LenType: "fixed", MaxLen: 8},
+		2:  {ContentType: "n", LenType: "lbin", MaxLen: 19, HeaderHex: true},
+		48: {ContentType: "ans", LenType: "llbin", MaxLen: 999},
+		55: {ContentType: "b", LenType: "lbin", MaxLen: 255, HeaderHex: true, Contain: "chip-tag"},
+		56: {ContentType: "b", LenType: "lbin", MaxLen: 255, HeaderHex: true},
+	})
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0100")
+	one.AddField(2, "4761739001010010123")
+	one.AddField(48, "ABC")
+	one.AddField(55, "9f02060000000001009f2701809c0100")
+	one.AddField(56, "0102")
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack message with binary lengths: %s", err.Error())
+	}
+
+	elements := hex.EncodeToString([]byte(packed[20:]))
+	expected := "13" + "04761739001010010123" + "0003" + "414243" + "10" + "9f02060000000001009f2701809c0100" + "02" + "0102"
+	if elements != expected {
+		t.Errorf("Expected %s but got %s", expected, elements)
+	}
+
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse message with binary lengths: %s", err.Error())
+	}
+	for field, value := range one.Elements.GetElements() {
+		if parsed.Elements.GetElements()[field] != value {
+			t.Errorf("field %d: expected %s but got %s", field, value, parsed.Elements.GetElements()[field])
+		}
+	}
+
+	// chip data behind a binary prefix is not padded to whole pairs of bytes
+	one.AddField(55, "9f0201009c0100")
+	packed, err = one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack odd length chip data: %s", err.Error())
+	}
+	elements = hex.EncodeToString([]byte(packed[20:]))
+	if expected = "07" + "9f0201009c0100" + "02" + "0102"; !strings.HasSuffix(elements, expected) {
+		t.Errorf("Expected %s to end with %s", elements, expected)
+	}
+	parsed, err = one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse odd length chip data: %s", err.Error())
+	}
+	if chip := parsed.Elements.GetElements()[55]; chip != "9f0201009c0100" {
+		t.Errorf("field 55: expected 9f0201009c0100 but got %s", chip)
+	}
+	if other := parsed.Elements.GetElements()[56]; other != "0102" {
+		t.Errorf("field 56: expected 0102 but got %s", other)
+	}
+
+	err = one.AddField(55, strings.Repeat("00", 256))
+	if err == nil {
+		t.Errorf("should refuse a value longer than the max length")
+	}
+	one.LazyValidation = true
+	one.AddField(55, strings.Repeat("00", 256))
+	_, err = one.ToString()
+	if err == nil {
+		t.Errorf("should throw an error when the length does not fit in the prefix")
+	}
+}
+
+func TestTertiaryBitmap(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:   {ContentType: "n", LenType: "fixed", MaxLen: 4},
+		1:   {ContentType: "b", LenType: "fixed", MaxLen: 8},
+		3:   {ContentType: "n", LenType: "fixed", MaxLen: 6},
+		70:  {ContentType: "n", LenType: "fixed", MaxLen: 3},
+		130: {ContentType: "ans", LenType: "llvar", MaxLen: 99},
+	})
+	one := NewISOStructFromSpecWithBitmaps(spec, 3)
+	one.AddMTI("0800")
+	one.AddField(3, "990000")
+	one.AddField(70, "301")
+	one.AddField(130, "tertiary")
+
+	if err := one.AddField(65, "1"); err == nil {
+		t.Errorf("field 65 should not accept data when a tertiary bitmap is present")
+	}
+
+	expected := "0800" + "a000000000000000" + "8400000000000000" + "4000000000000000" + "990000" + "301" + "08tertiary"
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack tertiary bitmap message: %s", err.Error())
+	}
+	if packed != expected {
+		t.Errorf("%s should be %s", packed, expected)
+	}
+
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse tertiary bitmap message: %s", err.Error())
+	}
+	if len(parsed.Bitmap) != 192 {
+		t.Errorf("expected a 192 bit bitmap found %d", len(parsed.Bitmap))
+	}
+	if parsed.Elements.GetElements()[130] != "tertiary" {
+		t.Errorf("expected field 130 to be tertiary found %s", parsed.Elements.GetElements()[130])
+	}
+
+	// a spec describing fields beyond 128 reads the tertiary bitmap whatever the parser was built with
+	primary := NewISOStructFromSpec(spec, false)
+	parsed, err = primary.Parse(packed)
+	if err != nil || len(parsed.Bitmap) != 192 {
+		t.Errorf("expected the spec to select the tertiary bitmap found %v", err)
+	}
+}
+
+func TestPackUnpack(t *testing.T) {
+	isobyte, _ := hex.DecodeString("60000000180810202001000280000292000000018200184c4537373030303030360044004232324230204b4559444c20494e4620494e56414c49442020202020202020202020202020202020202020")
+
+	parsed := NewISOStruct("spec1987pos3.yml", false)
+	err := parsed.Unpack(isobyte)
+	if err != nil {
+		t.Fatalf("failed to unpack valid isomsg: %s", err.Error())
+	}
+	if parsed.Mti.String() != "0810" {
+		t.Errorf("expected mti 0810 found %s", parsed.Mti.String())
+	}
+
+	text, ok := parsed.GetField(39)
+	if !ok || text != "LE" {
+		t.Errorf("expected field 39 to be LE found %s", text)
+	}
+	raw, err := parsed.GetFieldBytes(63)
+	if err != nil || string(raw) != "\x00B22B0 KEYDL INF INVALID                    " {
+		t.Errorf("unexpected bytes for field 63: %q", raw)
+	}
+
+	packed, err := parsed.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack valid isomsg: %s", err.Error())
+	}
+	if !bytes.Equal(packed, isobyte) {
+		t.Errorf("%x should be %x", packed, isobyte)
+	}
+
+	var _ encoding.BinaryMarshaler = &parsed
+	var _ encoding.BinaryUnmarshaler = &parsed
+
+	one := NewISOStruct("spec1987pos3.yml", false)
+	one.Header = nil
+	one.AddMTI("0800")
+	one.AddFieldBytes(63, []byte("HELLO"))
+	if value, _ := one.GetField(63); value != "48454c4c4f" {
+		t.Errorf("expected field 63 to be held as hex found %s", value)
+	}
+}
+
+func TestPaddedFields(t *testing.T) {
+	one := NewISOStruct("spec1987.yml", false)
+	one.AddMTI("0200")
+	one.AddField(3, "10")
+	one.AddField(4, "1500")
+	one.AddField(41, "TERM1")
+
+	expected := "0200" + "3000000000800000" + "000010" + "000000001500" + "TERM1   "
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack short fixed fields: %s", err.Error())
+	}
+	if packed != expected {
+		t.Errorf("%s should be %s", packed, expected)
+	}
+
+	err = one.AddField(4, "1234567890123")
+	if err == nil {
+		t.Errorf("should refuse a value longer than the max length")
+	}
+	one.LazyValidation = true
+	one.AddField(4, "1234567890123")
+	_, err = one.ToString()
+	if err == nil {
+		t.Errorf("should throw an error when a fixed field exceeds its max length")
+	}
+}
+
+func TestSecondaryBitmapField65(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:  {ContentType: "n", LenType: "fixed", MaxLen: 4},
+		1:  {ContentType: "b", LenType: "fixed", MaxLen: 8},
+		3:  {ContentType: "n", LenType: "fixed", MaxLen: 6},
+		65: {ContentType: "an", LenType: "fixed", MaxLen: 3},
+		70: {ContentType: "n", LenType: "fixed", MaxLen: 3},
+	})
+	one := NewISOStructFromSpecWithBitmaps(spec, 2)
+	one.AddMTI("0800")
+	one.AddField(3, "990000")
+	if err := one.AddField(65, "A01"); err != nil {
+		t.Fatalf("field 65 should carry data without a tertiary bitmap: %s", err.Error())
+	}
+	one.AddField(70, "301")
+
+	expected := "0800" + "a000000000000000" + "8400000000000000" + "990000" + "A01" + "301"
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack field 65: %s", err.Error())
+	}
+	if packed != expected {
+		t.Errorf("%s should be %s", packed, expected)
+	}
+
+	for _, parser := range []IsoStruct{one, NewISOStructFromSpec(spec, false), {Spec: spec}} {
+		parsed, err := parser.Parse(packed)
+		if err != nil {
+			t.Fatalf("failed to parse field 65: %s", err.Error())
+		}
+		if len(parsed.Bitmap) != 128 {
+			t.Errorf("expected a 128 bit bitmap found %d", len(parsed.Bitmap))
+		}
+		if value, _ := parsed.GetField(65); value != "A01" {
+			t.Errorf("expected field 65 to be A01 found %s", value)
+		}
+		if value, _ := parsed.GetField(70); value != "301" {
+			t.Errorf("expected field 70 to be 301 found %s", value)
+		}
+	}
+}
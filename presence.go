@@ -0,0 +1,167 @@
+package iso8583
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	"github.com/go-yaml/yaml"
+)
+
+// RulePresence is broken by a missing mandatory field or an unexpected one
+const RulePresence = "presence"
+
+// Presence lists the fields a kind of message must carry, may carry
+// when its conditions are met and may carry at will, any other field
+// is unexpected. Conditions are left to the host, conditional fields
+// are only allowed
+type Presence struct {
+	Mandatory   []int `yaml:"mandatory,omitempty"`
+	Conditional []int `yaml:"conditional,omitempty"`
+	Optional    []int `yaml:"optional,omitempty"`
+}
+
+// presenceKey names the rules for an mti, narrowed to a transaction
+// type (the first two digits of field 3) when one is given
+func presenceKey(mti string, transactionType string) string {
+	if transactionType == "" {
+		return mti
+	}
+	return mti + "/" + transactionType
+}
+
+// validPresenceKey checks a key is an mti with an optional transaction type
+func validPresenceKey(key string) bool {
+	if len(key) != 4 && (len(key) != 7 || key[4] != '/') {
+		return false
+	}
+	for i := 0; i < len(key); i++ {
+		if i != 4 && (key[i] < '0' || key[i] > '9') {
+			return false
+		}
+	}
+	return true
+}
+
+// readPresence reads the presence directive, its keys are quoted
+// mtis as yaml would otherwise take 0200 for an octal number
+func (s *Spec) readPresence(value interface{}) error {
+	rules, ok := value.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("spec error: presence should map mtis to their fields")
+	}
+	if s.presence == nil {
+		s.presence = make(map[string]Presence)
+	}
+	for key, rule := range rules {
+		k, ok := key.(string)
+		if !ok || !validPresenceKey(k) {
+			return fmt.Errorf("spec error: presence %v should be a quoted mti, optionally followed by a transaction type as in \"0200/01\"", key)
+		}
+		out, err := yaml.Marshal(rule)
+		if err != nil {
+			return err
+		}
+		var presence Presence
+		err = yaml.Unmarshal(out, &presence)
+		if err != nil {
+			return fmt.Errorf("spec error: presence %s: %s", k, err.Error())
+		}
+		s.presence[k] = presence
+	}
+	return nil
+}
+
+// validatePresence checks the presence rules are keyed by mti and
+// only list data fields the spec describes, each of them once
+func (s *Spec) validatePresence() SpecErrors {
+	keys := make([]string, 0, len(s.presence))
+	for key := range s.presence {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var errs SpecErrors
+	for _, key := range keys {
+		if !validPresenceKey(key) {
+			errs = append(errs, SpecError{Field: "0", Label: s.fields[0].Label, Problem: fmt.Sprintf("presence %s: should be an mti, optionally followed by a transaction type", key)})
+		}
+		presence := s.presence[key]
+		listed := map[int]bool{}
+		for _, fields := range [][]int{presence.Mandatory, presence.Conditional, presence.Optional} {
+			for _, field := range fields {
+				problem := ""
+				switch {
+				case field < 2 || field > maxField:
+					problem = "is not a data field"
+				case listed[field]:
+					problem = "is listed more than once"
+				default:
+					if _, ok := s.fields[field]; !ok {
+						problem = "is not described by the spec"
+					}
+				}
+				if problem != "" {
+					errs = append(errs, SpecError{Field: strconv.Itoa(field), Label: s.fields[field].Label, Problem: fmt.Sprintf("presence %s: %s", key, problem)})
+				}
+				listed[field] = true
+			}
+		}
+	}
+	return errs
+}
+
+// Presence returns the presence rules for an mti, the rules of the mti
+// and transaction type are preferred over those of the mti alone
+func (s Spec) Presence(mti string, transactionType string) (Presence, bool) {
+	if transactionType != "" {
+		if presence, ok := s.presence[presenceKey(mti, transactionType)]; ok {
+			return presence, true
+		}
+	}
+	presence, ok := s.presence[mti]
+	return presence, ok
+}
+
+// clone copies the field lists of the rules
+func (presence Presence) clone() Presence {
+	return Presence{
+		Mandatory:   append([]int(nil), presence.Mandatory...),
+		Conditional: append([]int(nil), presence.Conditional...),
+		Optional:    append([]int(nil), presence.Optional...),
+	}
+}
+
+// checkPresence finds the mandatory fields a message is missing and the
+// fields it carries that its rules do not expect, messages the spec has
+// no rules for are not checked
+func (iso *IsoStruct) checkPresence() ValidationErrors {
+	transactionType := ""
+	if processingCode := iso.Elements.elements[3]; len(processingCode) >= 2 {
+		transactionType = processingCode[:2]
+	}
+	presence, ok := iso.Spec.Presence(iso.Mti.String(), transactionType)
+	if !ok {
+		return nil
+	}
+
+	var errs ValidationErrors
+	expected := map[int64]bool{}
+	for _, field := range presence.Mandatory {
+		expected[int64(field)] = true
+		if _, ok := iso.Elements.elements[int64(field)]; !ok {
+			errs = append(errs, ValidationError{Field: field, Label: iso.Spec.fields[field].Label, Rule: RulePresence, message: fmt.Sprintf("mandatory in %s", iso.Mti.String())})
+		}
+	}
+	for _, fields := range [][]int{presence.Conditional, presence.Optional} {
+		for _, field := range fields {
+			expected[int64(field)] = true
+		}
+	}
+	for _, field := range iso.fieldNumbers() {
+		if !expected[field] {
+			errs = append(errs, ValidationError{Field: int(field), Label: iso.Spec.fields[int(field)].Label, Rule: RulePresence, Value: iso.Elements.elements[field], message: fmt.Sprintf("unexpected in %s", iso.Mti.String())})
+		}
+	}
+	return errs
+}
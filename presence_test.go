@@ -0,0 +1,156 @@
+package iso8583
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const presenceSpec = `extends: spec1987.yml
+presence:
+  "0200":
+    mandatory: [2, 3, 4, 11, 41]
+    conditional: [14]
+    optional: [49]
+  "0200/31":
+    mandatory: [2, 3, 11, 41]
+  "0800":
+    mandatory: [11, 70]
+`
+
+func TestPresence(t *testing.T) {
+	spec, err := SpecFromBytes([]byte(presenceSpec))
+	if err != nil {
+		t.Fatalf("failed to read spec with presence rules: %s", err.Error())
+	}
+	if presence, ok := spec.Presence("0200", "31"); !ok || !reflect.DeepEqual(presence.Mandatory, []int{2, 3, 11, 41}) {
+		t.Errorf("expected the balance inquiry rules found %#v", presence)
+	}
+	if presence, ok := spec.Presence("0200", "00"); !ok || len(presence.Mandatory) != 5 {
+		t.Errorf("expected purchases to fall back to the 0200 rules found %#v", presence)
+	}
+	if _, ok := spec.Presence("0400", ""); ok {
+		t.Errorf("expected no rules for 0400")
+	}
+
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0200")
+	one.AddField(2, "4111111111111111")
+	one.AddField(3, "000000")
+	one.AddField(11, "000001")
+	one.AddField(22, "051")
+	_, err = one.ToString()
+	errs, ok := err.(ValidationErrors)
+	if !ok || len(errs) != 3 {
+		t.Fatalf("expected fields 4 and 41 missing and 22 unexpected found %v", err)
+	}
+	expected := []struct {
+		field   int
+		message string
+	}{
+		{4, "field 4: mandatory in 0200"},
+		{41, "field 41: mandatory in 0200"},
+		{22, "field 22: unexpected in 0200"},
+	}
+	for i, test := range expected {
+		if errs[i].Field != test.field || errs[i].Rule != RulePresence || errs[i].Error() != test.message {
+			t.Errorf("expected %s found %+v", test.message, errs[i])
+		}
+	}
+
+	one.RemoveField(22)
+	one.AddField(4, "000000001500")
+	one.AddField(41, "TERM0001")
+	one.AddField(49, "840")
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("expected a complete purchase to pack: %s", err.Error())
+	}
+	if _, err = one.Parse(packed); err != nil {
+		t.Errorf("expected a complete purchase to parse: %s", err.Error())
+	}
+
+	one.RemoveField(4)
+	one.RemoveField(49)
+	one.AddField(3, "310000")
+	balance, err := one.ToString()
+	if err != nil {
+		t.Fatalf("expected a balance inquiry without an amount to pack: %s", err.Error())
+	}
+
+	strictSpec, err := spec.Builder().Presence("0200", "31", Presence{Mandatory: []int{2, 3, 4, 11, 41}}).Build()
+	if err != nil {
+		t.Fatalf("failed to build presence rules: %s", err.Error())
+	}
+	strict := NewISOStructFromSpec(strictSpec, false)
+	_, err = strict.Parse(balance)
+	if errs, ok := err.(ValidationErrors); !ok || len(errs) != 1 || errs[0].Field != 4 {
+		t.Errorf("expected parse to find field 4 missing found %v", err)
+	}
+	strict.LazyValidation = true
+	parsed, err := strict.Parse(balance)
+	if err != nil {
+		t.Fatalf("expected lazy parse to leave presence for later: %s", err.Error())
+	}
+	if errs := parsed.Validate(); len(errs) != 1 || errs[0].Rule != RulePresence {
+		t.Errorf("expected Validate to find field 4 missing found %v", errs)
+	}
+}
+
+func TestPresenceSpecErrors(t *testing.T) {
+	tests := []struct {
+		content  string
+		expected string
+	}{
+		{"presence:\n  0200:\n    mandatory: [2]\n", "should be a quoted mti"},
+		{"presence:\n  \"0200/3\":\n    mandatory: [2]\n", "should be a quoted mti"},
+		{"presence:\n  \"0200\":\n    mandatory: [1]\n", "field 1 (Bitmap): presence 0200: is not a data field"},
+		{"presence:\n  \"0200\":\n    mandatory: [2]\n    optional: [2]\n", "presence 0200: is listed more than once"},
+		{"presence:\n  \"0200\":\n    optional: [130]\n", "field 130: presence 0200: is not described by the spec"},
+	}
+	for _, test := range tests {
+		_, err := SpecFromBytes([]byte("extends: spec1987.yml\n" + test.content))
+		if err == nil || !strings.Contains(err.Error(), test.expected) {
+			t.Errorf("%q: expected %q found %v", test.content, test.expected, err)
+		}
+	}
+
+	_, err := Specs["1987"].Builder().Presence("02000", "", Presence{Mandatory: []int{2}}).Build()
+	if err == nil || !strings.Contains(err.Error(), "presence 02000: should be an mti") {
+		t.Errorf("expected an invalid mti to fail found %v", err)
+	}
+}
+
+func TestPresenceExtendsAndYAML(t *testing.T) {
+	base, _ := SpecFromBytes([]byte(presenceSpec))
+	RegisterSpec("test-presence", base)
+	defer delete(Specs, "test-presence")
+
+	spec, err := SpecFromBytes([]byte("extends: test-presence\npresence:\n  \"0800\":\n    mandatory: [11]\n    optional: [70]\n"))
+	if err != nil {
+		t.Fatalf("failed to extend presence rules: %s", err.Error())
+	}
+	if presence, _ := spec.Presence("0800", ""); len(presence.Mandatory) != 1 || len(presence.Optional) != 1 {
+		t.Errorf("expected the 0800 rules to be replaced found %#v", presence)
+	}
+	if presence, _ := spec.Presence("0200", ""); len(presence.Mandatory) != 5 {
+		t.Errorf("expected the 0200 rules from the base found %#v", presence)
+	}
+	if presence, _ := base.Presence("0800", ""); len(presence.Mandatory) != 2 {
+		t.Errorf("expected the base to be untouched found %#v", presence)
+	}
+
+	var out bytes.Buffer
+	err = spec.WriteYAML(&out)
+	if err != nil {
+		t.Fatalf("failed to write yaml: %s", err.Error())
+	}
+	again, err := SpecFromBytes(out.Bytes())
+	if err != nil {
+		t.Fatalf("failed to read written yaml: %s", err.Error())
+	}
+	if !reflect.DeepEqual(again, spec) {
+		t.Errorf("presence rules changed going through yaml")
+	}
+}
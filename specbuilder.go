@@ -0,0 +1,104 @@
+package iso8583
+
+// SpecBuilder puts a spec together field by field from go code
+type SpecBuilder struct {
+	spec Spec
+}
+
+// NewSpecBuilder returns a builder for a spec with no fields
+func NewSpecBuilder() *SpecBuilder {
+	return &SpecBuilder{spec: Spec{fields: make(map[int]FieldDescription)}}
+}
+
+// Builder returns a builder starting from a copy of s
+// so individual fields can be overridden
+func (s Spec) Builder() *SpecBuilder {
+	return &SpecBuilder{spec: s.Clone()}
+}
+
+// Field describes a field, replacing any earlier description of it
+func (b *SpecBuilder) Field(field int, fieldDescription FieldDescription) *SpecBuilder {
+	if b.spec.fields == nil {
+		b.spec.fields = make(map[int]FieldDescription)
+	}
+	b.spec.fields[field] = fieldDescription.clone()
+	return b
+}
+
+// Fixed describes a fixed length field
+func (b *SpecBuilder) Fixed(field int, contentType string, length int, label string) *SpecBuilder {
+	return b.Field(field, FieldDescription{ContentType: contentType, LenType: "fixed", MaxLen: length, Label: label})
+}
+
+// Variable describes a variable length field, lenType being
+// one of the llvar family or lbin and llbin
+func (b *SpecBuilder) Variable(field int, contentType string, lenType string, minLen int, maxLen int, label string) *SpecBuilder {
+	return b.Field(field, FieldDescription{ContentType: contentType, LenType: lenType, MinLen: minLen, MaxLen: maxLen, Label: label})
+}
+
+// Encoding sets the character encoding of a field that is already described
+func (b *SpecBuilder) Encoding(field int, encoding string) *SpecBuilder {
+	fieldDescription := b.spec.fields[field]
+	fieldDescription.Encoding = encoding
+	b.spec.fields[field] = fieldDescription
+	return b
+}
+
+// Validators names FieldValidators a field that is already described must pass
+func (b *SpecBuilder) Validators(field int, names ...string) *SpecBuilder {
+	fieldDescription := b.spec.fields[field]
+	fieldDescription.Validators = append(fieldDescription.Validators, names...)
+	b.spec.fields[field] = fieldDescription
+	return b
+}
+
+// Validator attaches a validator to a field that is already described,
+// the name is the rule of the errors it reports and is only known to the
+// specs built here, where it takes over a registered validator of the same
+// name. Spec files can not name it, WriteYAML writes the name alone
+func (b *SpecBuilder) Validator(field int, name string, validator FieldValidator) *SpecBuilder {
+	if b.spec.validators == nil {
+		b.spec.validators = make(map[string]FieldValidator)
+	}
+	b.spec.validators[name] = validator
+	return b.Validators(field, name)
+}
+
+// Presence sets the fields messages of an mti must or may carry,
+// narrowed to a transaction type unless it is empty
+func (b *SpecBuilder) Presence(mti string, transactionType string, presence Presence) *SpecBuilder {
+	if b.spec.presence == nil {
+		b.spec.presence = make(map[string]Presence)
+	}
+	b.spec.presence[presenceKey(mti, transactionType)] = presence.clone()
+	return b
+}
+
+// Remove drops the description of a field
+func (b *SpecBuilder) Remove(field int) *SpecBuilder {
+	delete(b.spec.fields, field)
+	return b
+}
+
+// Header selects the header messages are sent behind, as the header
+// and headerlength keys of a spec file do
+func (b *SpecBuilder) Header(kind string, length int) *SpecBuilder {
+	b.spec.header = kind
+	b.spec.headerLength = length
+	return b
+}
+
+// Build checks the spec the same way a spec file is checked and returns it,
+// the builder can go on to build further specs
+func (b *SpecBuilder) Build() (Spec, error) {
+	spec := b.spec.Clone()
+	_, err := spec.Header()
+	if err != nil {
+		return Spec{}, err
+	}
+	err = spec.validate()
+	if err != nil {
+		return Spec{}, err
+	}
+	return spec, nil
+}
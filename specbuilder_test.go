@@ -0,0 +1,75 @@
+package iso8583
+
+import "testing"
+
+func TestSpecBuilder(t *testing.T) {
+	spec, err := NewSpecBuilder().
+		Fixed(0, "n", 4, "Message type indicator").
+		Fixed(1, "b", 8, "Bitmap").
+		Variable(2, "n", "llvar", 12, 19, "Primary account number (PAN)").
+		Fixed(11, "n", 6, "Systems trace audit number").
+		Fixed(41, "ans", 8, "Card acceptor terminal identification").
+		Encoding(41, "ebcdic").
+		Header("tpdu", 0).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build spec: %s", err.Error())
+	}
+
+	pan, ok := spec.Field(2)
+	if !ok || pan.LenType != "llvar" || pan.MinLen != 12 || pan.MaxLen != 19 {
+		t.Errorf("unexpected description of field 2: %#v", pan)
+	}
+	if fields := spec.Fields(); len(fields) != 5 || fields[4] != 41 {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0800")
+	one.AddField(2, "4111111111111111")
+	one.AddField(41, "TERM0001")
+	packed, err := one.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	if string(packed[len(packed)-8:]) != "\xe3\xc5\xd9\xd4\xf0\xf0\xf0\xf1" {
+		t.Errorf("expected field 41 in ebcdic found %x", packed[len(packed)-8:])
+	}
+
+	_, err = NewSpecBuilder().Fixed(0, "n", 4, "Message type indicator").Variable(2, "n", "xvar", 0, 19, "").Build()
+	errs, ok := err.(SpecErrors)
+	if !ok || len(errs) != 2 {
+		t.Errorf("expected a missing bitmap and an invalid LenType found %v", err)
+	}
+}
+
+func TestSpecClone(t *testing.T) {
+	base, _ := SpecFromFile("spec1987.yml")
+	spec, err := base.Builder().
+		Fixed(41, "ans", 16, "Card acceptor terminal identification").
+		Remove(128).
+		Build()
+	if err != nil {
+		t.Fatalf("failed to build spec: %s", err.Error())
+	}
+
+	if fieldDescription, _ := spec.Field(41); fieldDescription.MaxLen != 16 {
+		t.Errorf("expected overridden field 41 found %#v", fieldDescription)
+	}
+	if fieldDescription, _ := base.Field(41); fieldDescription.MaxLen != 8 {
+		t.Errorf("expected base field 41 to be untouched found %#v", fieldDescription)
+	}
+	if _, ok := spec.Field(128); ok {
+		t.Errorf("expected field 128 to be removed")
+	}
+	if _, ok := base.Field(128); !ok {
+		t.Errorf("expected base field 128 to be untouched")
+	}
+
+	withSubfields := Spec{fields: map[int]FieldDescription{48: {Subfields: []FieldDescription{{ID: "1"}}}}}
+	clone := withSubfields.Clone()
+	clone.fields[48].Subfields[0].ID = "2"
+	if withSubfields.fields[48].Subfields[0].ID != "1" {
+		t.Errorf("expected subfields to be copied")
+	}
+}
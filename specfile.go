@@ -1,26 +1,59 @@
 package iso8583
 
 import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
 	"io/ioutil"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/go-yaml/yaml"
 )
 
 // FieldDescription contains fields that describes an iso8583 Field
 type FieldDescription struct {
-	ContentType string `yaml:"ContentType"`
-	MaxLen      int    `yaml:"MaxLen"`
-	MinLen      int    `yaml:"MinLen"`
-	LenType     string `yaml:"LenType"`
-	Label       string `yaml:"Label"`
-	HeaderHex   bool   `yaml:"HeaderHex"`
-	Contain     string `yaml:"Contain"`
+	ContentType string `yaml:"ContentType,omitempty"`
+	MaxLen      int    `yaml:"MaxLen,omitempty"`
+	MinLen      int    `yaml:"MinLen,omitempty"`
+	LenType     string `yaml:"LenType,omitempty"`
+	Label       string `yaml:"Label,omitempty"`
+	HeaderHex   bool   `yaml:"HeaderHex,omitempty"`
+	Contain     string `yaml:"Contain,omitempty"`
+	Encoding    string `yaml:"Encoding,omitempty"`
+
+	// BcdPad and BcdJustify place the pad nibble of odd length values of
+	// bcd packed fields (numbers and track data held as hex) and apply to
+	// nothing else. Pad and Justify fill fixed fields up to MaxLen before
+	// they are packed, on bcd packed fields Pad has to be a digit and
+	// Justify may not differ from BcdJustify
+	BcdPad     string `yaml:"BcdPad,omitempty"`
+	BcdJustify string `yaml:"BcdJustify,omitempty"`
+	Pad        string `yaml:"Pad,omitempty"`
+	Justify    string `yaml:"Justify,omitempty"`
+
+	// Validators name FieldValidators the value must pass as well
+	Validators []string `yaml:"Validators,omitempty"`
+
+	// ID names a subfield, e.g. 2 in 48.2 or TableID in 63.TableID
+	ID             string             `yaml:"ID,omitempty"`
+	SubfieldFormat string             `yaml:"SubfieldFormat,omitempty"`
+	TagLen         int                `yaml:"TagLen,omitempty"`
+	Subfields      []FieldDescription `yaml:"Subfields,omitempty"`
 }
 
 // Spec contains a strutured description of an iso8583 spec
 // properly defined by a spec file
 type Spec struct {
-	fields map[int]FieldDescription
+	fields       map[int]FieldDescription
+	header       string
+	headerLength int
+	presence     map[string]Presence
+	validators   map[string]FieldValidator
 }
 
 // readFromFile reads a yaml specfile and loads
@@ -30,10 +63,171 @@ func (s *Spec) readFromFile(filename string) error {
 	if err != nil {
 		return err
 	}
-	yaml.Unmarshal(content, &s.fields) // expecting content to be valid yaml
+	return s.read(content, fileLoader(filepath.Dir(filename)), 0)
+}
+
+// readFromBytes loads a spec from yaml held in memory,
+// it can extend registered specs and the ones shipped with the package
+func (s *Spec) readFromBytes(content []byte) error {
+	return s.read(content, nil, 0)
+}
+
+// maxExtends is how deep specs may extend one another
+const maxExtends = 8
+
+// specLoader reads the spec file another one extends, names are
+// relative to the extending file
+type specLoader func(name string) ([]byte, specLoader, error)
+
+// fileLoader loads extended specs from disk
+func fileLoader(dir string) specLoader {
+	return func(name string) ([]byte, specLoader, error) {
+		if !filepath.IsAbs(name) {
+			name = filepath.Join(dir, name)
+		}
+		content, err := ioutil.ReadFile(name)
+		return content, fileLoader(filepath.Dir(name)), err
+	}
+}
+
+// fsLoader loads extended specs from a file system
+func fsLoader(fsys fs.FS, dir string) specLoader {
+	return func(name string) ([]byte, specLoader, error) {
+		name = path.Join(dir, name)
+		content, err := fs.ReadFile(fsys, name)
+		return content, fsLoader(fsys, path.Dir(name)), err
+	}
+}
+
+// read loads a spec from yaml, numbered keys describe fields
+// while named keys such as header are directives for the whole spec.
+// A spec that extends another starts from a copy of it and only the
+// attributes it lists replace those of the base field
+func (s *Spec) read(content []byte, load specLoader, depth int) error {
+	var raw map[interface{}]interface{}
+	err := yaml.Unmarshal(content, &raw)
+	if err != nil {
+		return err
+	}
+
+	s.fields = make(map[int]FieldDescription)
+	if extends, ok := raw["extends"]; ok {
+		name, ok := extends.(string)
+		if !ok {
+			return fmt.Errorf("spec error: extends should be a string")
+		}
+		err = s.readBase(name, load, depth)
+		if err != nil {
+			return err
+		}
+	}
+
+	for key, value := range raw {
+		if field, ok := fieldKey(key); ok {
+			// go through yaml again so the field keeps its own tags
+			out, err := yaml.Marshal(value)
+			if err != nil {
+				return err
+			}
+			fieldDescription := s.fields[field].clone()
+			err = yaml.Unmarshal(out, &fieldDescription)
+			if err != nil {
+				return fmt.Errorf("spec error: field %d: %s", field, err.Error())
+			}
+			s.fields[field] = fieldDescription
+			continue
+		}
+
+		k, ok := key.(string)
+		if !ok {
+			return fmt.Errorf("spec error: %v is an invalid key", key)
+		}
+		if k == "extends" {
+			continue
+		}
+		err = s.readDirective(k, value)
+		if err != nil {
+			return err
+		}
+	}
+	_, err = s.Header()
+	if err != nil {
+		return err
+	}
+	return s.validate()
+}
+
+// fieldKey returns the field number a key stands for,
+// json only has string keys so numbers are accepted as strings too
+func fieldKey(key interface{}) (int, bool) {
+	switch k := key.(type) {
+	case int:
+		return k, true
+	case string:
+		field, err := strconv.Atoi(k)
+		return field, err == nil
+	}
+	return 0, false
+}
+
+// readBase loads the spec named by extends into s, registered specs
+// are found by name and anything else is read as a file next to the
+// extending one or from the specs shipped with the package
+func (s *Spec) readBase(name string, load specLoader, depth int) error {
+	if depth >= maxExtends {
+		return fmt.Errorf("spec error: extends %s: more than %d levels deep", name, maxExtends)
+	}
+	if base, ok := Specs[name]; ok {
+		*s = base.Clone()
+		return nil
+	}
+
+	if load == nil {
+		load = fsLoader(specFiles, ".")
+	}
+	content, next, err := load(name)
+	if errors.Is(err, fs.ErrNotExist) {
+		var shippedErr error
+		content, next, shippedErr = fsLoader(specFiles, ".")(name)
+		if shippedErr == nil {
+			err = nil
+		}
+	}
+	if err != nil {
+		return fmt.Errorf("spec error: extends %s: %s", name, err.Error())
+	}
+	return s.read(content, next, depth+1)
+}
+
+// readDirective sets a spec wide setting
+func (s *Spec) readDirective(key string, value interface{}) error {
+	switch key {
+	case "header":
+		header, ok := value.(string)
+		if !ok {
+			return fmt.Errorf("spec error: header should be a string")
+		}
+		s.header = header
+	case "headerlength":
+		length, ok := value.(int)
+		if !ok {
+			return fmt.Errorf("spec error: headerlength should be a number")
+		}
+		s.headerLength = length
+	case "presence":
+		return s.readPresence(value)
+	default:
+		return fmt.Errorf("spec error: %s is an invalid key", key)
+	}
 	return nil
 }
 
+// Header returns a new header of the kind the spec selects,
+// nil when messages of this spec are sent without a header
+func (s Spec) Header() (Header, error) {
+	return newHeader(s.header, s.headerLength)
+}
+
 // SpecFromFile returns a brand new empty spec
 func SpecFromFile(filename string) (Spec, error) {
 	s := Spec{}
@@ -43,3 +237,252 @@ func SpecFromFile(filename string) (Spec, error) {
 	}
 	return s, nil
 }
+
+// SpecFromReader reads a yaml spec from r
+func SpecFromReader(r io.Reader) (Spec, error) {
+	content, err := ioutil.ReadAll(r)
+	if err != nil {
+		return Spec{}, err
+	}
+	return SpecFromBytes(content)
+}
+
+// SpecFromBytes reads a yaml spec held in memory
+func SpecFromBytes(content []byte) (Spec, error) {
+	s := Spec{}
+	err := s.readFromBytes(content)
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// SpecFromFS reads a yaml spec from a file system,
+// such as one embedded into the binary
+func SpecFromFS(fsys fs.FS, name string) (Spec, error) {
+	content, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return Spec{}, err
+	}
+	s := Spec{}
+	err = s.read(content, fsLoader(fsys, path.Dir(name)), 0)
+	if err != nil {
+		return s, err
+	}
+	return s, nil
+}
+
+// Field returns the description of a field
+func (s Spec) Field(field int) (FieldDescription, bool) {
+	fieldDescription, ok := s.fields[field]
+	return fieldDescription, ok
+}
+
+// Fields returns the numbers of the fields the spec describes in order
+func (s Spec) Fields() []int {
+	fields := make([]int, 0, len(s.fields))
+	for field := range s.fields {
+		fields = append(fields, field)
+	}
+	sort.Ints(fields)
+	return fields
+}
+
+// hasTertiaryFields reports whether the spec describes fields only
+// a tertiary bitmap can flag
+func (s Spec) hasTertiaryFields() bool {
+	for field := range s.fields {
+		if field > 128 {
+			return true
+		}
+	}
+	return false
+}
+
+// Clone returns a deep copy of the spec that can be changed
+// without touching the original
+func (s Spec) Clone() Spec {
+	clone := s
+	clone.fields = make(map[int]FieldDescription, len(s.fields))
+	for field, fieldDescription := range s.fields {
+		clone.fields[field] = fieldDescription.clone()
+	}
+	if s.presence != nil {
+		clone.presence = make(map[string]Presence, len(s.presence))
+		for key, presence := range s.presence {
+			clone.presence[key] = presence.clone()
+		}
+	}
+	if s.validators != nil {
+		clone.validators = make(map[string]FieldValidator, len(s.validators))
+		for name, validator := range s.validators {
+			clone.validators[name] = validator
+		}
+	}
+	return clone
+}
+
+// clone copies a field description along with its subfields
+func (fieldDescription FieldDescription) clone() FieldDescription {
+	if fieldDescription.Validators != nil {
+		fieldDescription.Validators = append([]string(nil), fieldDescription.Validators...)
+	}
+	if fieldDescription.Subfields == nil {
+		return fieldDescription
+	}
+	subfields := make([]FieldDescription, len(fieldDescription.Subfields))
+	for i, subfield := range fieldDescription.Subfields {
+		subfields[i] = subfield.clone()
+	}
+	fieldDescription.Subfields = subfields
+	return fieldDescription
+}
+
+// maxField is the last field a tertiary bitmap can flag
+const maxField = 192
+
+// contentTypes are the content types a field can be described with
+var contentTypes = map[string]bool{
+	"a": true, "n": true, "s": true, "an": true, "as": true, "ns": true,
+	"ans": true, "anp": true, "b": true, "z": true, "x+n": true,
+}
+
+// SpecError is a problem with one field of a spec
+type SpecError struct {
+	Field   string
+	Label   string
+	Problem string
+}
+
+func (e SpecError) Error() string {
+	if e.Label == "" {
+		return fmt.Sprintf("spec error: field %s: %s", e.Field, e.Problem)
+	}
+	return fmt.Sprintf("spec error: field %s (%s): %s", e.Field, e.Label, e.Problem)
+}
+
+// SpecErrors lists every problem found in a spec
+type SpecErrors []SpecError
+
+func (e SpecErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "\n")
+}
+
+// validate checks the whole spec up front so mistakes
+// are not first found halfway through a message
+func (s *Spec) validate() error {
+	var errs SpecErrors
+	for _, field := range []int{0, 1} {
+		if _, ok := s.fields[field]; !ok {
+			errs = append(errs, SpecError{Field: strconv.Itoa(field), Problem: "missing"})
+		}
+	}
+
+	for _, field := range s.Fields() {
+		fieldDescription := s.fields[field]
+		if field < 0 || field > maxField {
+			errs = append(errs, SpecError{Field: strconv.Itoa(field), Label: fieldDescription.Label, Problem: fmt.Sprintf("beyond the last field of the bitmap %d", maxField)})
+			continue
+		}
+		errs = append(errs, s.validateField(fieldDescription, strconv.Itoa(field), true)...)
+	}
+	errs = append(errs, s.validatePresence()...)
+
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+// validateField checks a field and its subfields, attributes
+// subfields leave to their format are only checked when set
+func (s *Spec) validateField(fieldDescription FieldDescription, field string, topLevel bool) SpecErrors {
+	var errs SpecErrors
+	problem := func(format string, args ...interface{}) {
+		errs = append(errs, SpecError{Field: field, Label: fieldDescription.Label, Problem: fmt.Sprintf(format, args...)})
+	}
+
+	lenType := fieldDescription.LenType
+	if lenType != "" || topLevel {
+		_, binary := getBinaryLengthFromString(lenType)
+		if _, err := getVariableLengthFromString(lenType); lenType != "fixed" && !binary && err != nil {
+			problem("%s is an invalid LenType", lenType)
+		}
+	}
+	if fieldDescription.ContentType != "" || topLevel {
+		if !contentTypes[fieldDescription.ContentType] {
+			problem("%s is an invalid ContentType", fieldDescription.ContentType)
+		}
+	}
+	switch fieldDescription.Contain {
+	case "", "string", "chip-tag":
+	default:
+		problem("%s is an invalid Contain", fieldDescription.Contain)
+	}
+	if _, err := getCharset(fieldDescription.Encoding); err != nil {
+		problem(err.Error())
+	}
+	if topLevel && fieldDescription.MaxLen < 1 {
+		problem("MaxLen should be set")
+	}
+	if fieldDescription.MinLen < 0 || (fieldDescription.MaxLen > 0 && fieldDescription.MinLen > fieldDescription.MaxLen) {
+		problem("MinLen %d should be between 0 and MaxLen %d", fieldDescription.MinLen, fieldDescription.MaxLen)
+	}
+	for _, justify := range []string{fieldDescription.Justify, fieldDescription.BcdJustify} {
+		switch justify {
+		case "", "left", "right":
+		default:
+			problem("%s is an invalid justification", justify)
+		}
+	}
+	for _, pad := range []string{fieldDescription.Pad, fieldDescription.BcdPad} {
+		if len(pad) > 1 {
+			problem("pad %q should be a single character", pad)
+		}
+	}
+	if isNibblePacked(fieldDescription) {
+		if fieldDescription.Pad != "" && (fieldDescription.Pad < "0" || fieldDescription.Pad > "9") {
+			problem("Pad %q can not be packed as bcd, the pad nibble is set by BcdPad", fieldDescription.Pad)
+		}
+		if fieldDescription.Justify != "" && fieldDescription.BcdJustify != "" && fieldDescription.Justify != fieldDescription.BcdJustify {
+			problem("Justify %s conflicts with BcdJustify %s", fieldDescription.Justify, fieldDescription.BcdJustify)
+		}
+	} else if fieldDescription.BcdPad != "" || fieldDescription.BcdJustify != "" {
+		problem("BcdPad and BcdJustify only apply to bcd packed fields, use Pad and Justify")
+	}
+
+	if !topLevel && len(fieldDescription.Validators) > 0 {
+		problem("Validators only run on top level fields")
+	}
+	for _, name := range fieldDescription.Validators {
+		if _, ok := s.validator(name); !ok {
+			problem("%s is an unknown validator", name)
+		}
+	}
+
+	switch fieldDescription.SubfieldFormat {
+	case "", "position", "tlv", "bitmap":
+	default:
+		problem("%s is an invalid SubfieldFormat", fieldDescription.SubfieldFormat)
+	}
+	for i, subfield := range fieldDescription.Subfields {
+		id := subfieldID(subfield, i)
+		key := field + "." + id
+		// the bitmap of a bitmap formatted field is always 64 bits,
+		// every other subfield is read by its own length
+		if fieldDescription.SubfieldFormat != "bitmap" || id != "1" {
+			if subfield.LenType == "" {
+				errs = append(errs, SpecError{Field: key, Label: subfield.Label, Problem: "LenType should be set"})
+			}
+			if subfield.MaxLen < 1 {
+				errs = append(errs, SpecError{Field: key, Label: subfield.Label, Problem: "MaxLen should be set"})
+			}
+		}
+		errs = append(errs, s.validateField(subfield, key, false)...)
+	}
+	return errs
+}
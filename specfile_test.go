@@ -1,6 +1,13 @@
 package iso8583
 
-import "testing"
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"reflect"
+	"testing"
+	"testing/fstest"
+)
 
 func Test_ReadFile(t *testing.T) {
 	_, err := SpecFromFile("spec1987.yml")
@@ -8,3 +15,221 @@ func Test_ReadFile(t *testing.T) {
 		t.Errorf("failed to parse valid spec file %s", err.Error())
 	}
 }
+
+func TestSpecFilesValidate(t *testing.T) {
+	for _, filename := range []string{"spec1987.yml", "spec1987pos.yml", "spec1987pos2.yml", "spec1987pos3.yml"} {
+		_, err := SpecFromFile(filename)
+		if err != nil {
+			t.Errorf("%s: %s", filename, err.Error())
+		}
+	}
+}
+
+func TestSpecValidation(t *testing.T) {
+	yml := `
+0:
+  ContentType: "n"
+  LenType: fixed
+  MaxLen: 4
+2:
+  ContentType: "n"
+  Label: Primary account number (PAN)
+  LenType: lvar
+  MaxLen: 19
+  MinLen: 20
+3:
+  ContentType: "q"
+  LenType: fixed
+  MaxLen: 6
+48:
+  ContentType: ans
+  LenType: lllvar
+  MaxLen: 999
+  Contain: tlv
+  Subfields:
+    - ID: "1"
+      LenType: fixed
+      MaxLen: 2
+      MinLen: 3
+    - ID: "A"
+      ContentType: an
+127:
+  ContentType: b
+  LenType: llllvar
+  MaxLen: 9999
+  SubfieldFormat: bitmap
+  Subfields:
+    - HeaderHex: true
+    - ContentType: ans
+      LenType: llvar
+      MaxLen: 32
+200:
+  ContentType: "n"
+  LenType: fixed
+  MaxLen: 1
+`
+	var s Spec
+	err := s.readFromBytes([]byte(yml))
+	errs, ok := err.(SpecErrors)
+	if !ok {
+		t.Fatalf("expected SpecErrors found %v", err)
+	}
+
+	expected := []SpecError{
+		{Field: "1", Problem: "missing"},
+		{Field: "2", Label: "Primary account number (PAN)", Problem: "lvar is an invalid LenType"},
+		{Field: "2", Label: "Primary account number (PAN)", Problem: "MinLen 20 should be between 0 and MaxLen 19"},
+		{Field: "3", Problem: "q is an invalid ContentType"},
+		{Field: "48", Problem: "tlv is an invalid Contain"},
+		{Field: "48.1", Problem: "MinLen 3 should be between 0 and MaxLen 2"},
+		{Field: "48.A", Problem: "LenType should be set"},
+		{Field: "48.A", Problem: "MaxLen should be set"},
+		{Field: "200", Problem: "beyond the last field of the bitmap 192"},
+	}
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d problems found %d:\n%s", len(expected), len(errs), errs.Error())
+	}
+	for i := range expected {
+		if errs[i] != expected[i] {
+			t.Errorf("expected %#v found %#v", expected[i], errs[i])
+		}
+	}
+	if errs[1].Error() != "spec error: field 2 (Primary account number (PAN)): lvar is an invalid LenType" {
+		t.Errorf("unexpected message %s", errs[1].Error())
+	}
+
+	err = s.readFromBytes([]byte("0: [unclosed"))
+	if err == nil {
+		t.Errorf("expected invalid yaml to fail")
+	}
+}
+
+func TestSpecLoaders(t *testing.T) {
+	content, err := ioutil.ReadFile("spec1987pos.yml")
+	if err != nil {
+		t.Fatalf("failed to read spec1987pos.yml: %s", err.Error())
+	}
+	fromFile, _ := SpecFromFile("spec1987pos.yml")
+
+	fromBytes, err := SpecFromBytes(content)
+	if err != nil {
+		t.Errorf("SpecFromBytes failed: %s", err.Error())
+	}
+	fromReader, err := SpecFromReader(bytes.NewReader(content))
+	if err != nil {
+		t.Errorf("SpecFromReader failed: %s", err.Error())
+	}
+	fromFS, err := SpecFromFS(fstest.MapFS{"specs/pos.yml": {Data: content}}, "specs/pos.yml")
+	if err != nil {
+		t.Errorf("SpecFromFS failed: %s", err.Error())
+	}
+	for _, spec := range []Spec{fromBytes, fromReader, fromFS} {
+		if !reflect.DeepEqual(spec, fromFile) {
+			t.Errorf("spec loaded from memory differs from the file")
+		}
+	}
+
+	_, err = SpecFromFS(os.DirFS("."), "missing.yml")
+	if err == nil {
+		t.Errorf("expected missing spec to fail")
+	}
+	_, err = SpecFromBytes([]byte("0:\n  LenType: xvar\n"))
+	if err == nil {
+		t.Errorf("expected invalid spec to fail")
+	}
+
+	one := NewISOStructFromSpec(fromFS, true)
+	if len(one.Bitmap) != 128 || one.Bitmap[0] != 1 {
+		t.Errorf("expected a secondary bitmap")
+	}
+	if one.Header != (Tpdu{}) {
+		t.Errorf("expected the spec's tpdu header found %#v", one.Header)
+	}
+	one.AddMTI("0800")
+	one.AddField(11, "000001")
+	packed, err := one.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	two := NewISOStruct("spec1987pos.yml", true)
+	two.AddMTI("0800")
+	two.AddField(11, "000001")
+	expected, _ := two.Pack()
+	if !bytes.Equal(packed, expected) {
+		t.Errorf("%x should be %x", packed, expected)
+	}
+}
+
+func TestSpecExtends(t *testing.T) {
+	fsys := fstest.MapFS{
+		"acquirers/base.yml": {Data: []byte("extends: spec1987pos.yml\n41:\n  MaxLen: 16\n")},
+		"acquirers/bank.yml": {Data: []byte("extends: base.yml\nheader: visa\n52:\n  Label: PIN block\n")},
+		"loop/a.yml":         {Data: []byte("extends: b.yml\n")},
+		"loop/b.yml":         {Data: []byte("extends: a.yml\n")},
+	}
+
+	spec, err := SpecFromFS(fsys, "acquirers/bank.yml")
+	if err != nil {
+		t.Fatalf("failed to read extending spec: %s", err.Error())
+	}
+	pin, _ := spec.Field(52)
+	if pin.Label != "PIN block" || pin.MaxLen != 16 || !pin.HeaderHex || pin.LenType != "fixed" {
+		t.Errorf("expected only the label of field 52 to change found %#v", pin)
+	}
+	if terminal, _ := spec.Field(41); terminal.MaxLen != 16 || terminal.ContentType != "ans" {
+		t.Errorf("expected field 41 from base.yml found %#v", terminal)
+	}
+	if header, _ := spec.Header(); header != (VisaHeader{}) {
+		t.Errorf("expected the overriding visa header found %#v", header)
+	}
+	if len(spec.fields) != 129 {
+		t.Errorf("expected every base field found %d", len(spec.fields))
+	}
+	if pos, _ := Specs["1987pos"].Field(41); pos.MaxLen != 8 {
+		t.Errorf("expected the registered base to be untouched found %#v", pos)
+	}
+
+	fromBytes, err := SpecFromBytes([]byte("extends: \"1993\"\n39:\n  Label: Response code\n"))
+	if err != nil {
+		t.Fatalf("failed to extend a registered spec: %s", err.Error())
+	}
+	if action, _ := fromBytes.Field(39); action.Label != "Response code" || action.MaxLen != 3 {
+		t.Errorf("unexpected field 39 %#v", action)
+	}
+
+	_, err = SpecFromFS(fsys, "loop/a.yml")
+	if err == nil {
+		t.Errorf("expected specs extending each other to fail")
+	}
+	_, err = SpecFromBytes([]byte("extends: missing.yml\n"))
+	if err == nil {
+		t.Errorf("expected a missing base to fail")
+	}
+}
+
+func TestPaddingAttributes(t *testing.T) {
+	tests := []struct {
+		fieldDescription FieldDescription
+		expected         string
+	}{
+		{FieldDescription{ContentType: "n", LenType: "llvar", MaxLen: 19, HeaderHex: true, BcdPad: "F", BcdJustify: "left"}, ""},
+		{FieldDescription{ContentType: "z", LenType: "llvar", MaxLen: 37, HeaderHex: true, BcdPad: "F"}, ""},
+		{FieldDescription{ContentType: "n", LenType: "fixed", MaxLen: 6, HeaderHex: true, Pad: "F"}, `Pad "F" can not be packed as bcd, the pad nibble is set by BcdPad`},
+		{FieldDescription{ContentType: "n", LenType: "fixed", MaxLen: 5, HeaderHex: true, Justify: "left", BcdJustify: "right"}, "Justify left conflicts with BcdJustify right"},
+		{FieldDescription{ContentType: "ans", LenType: "fixed", MaxLen: 8, BcdPad: "F"}, "BcdPad and BcdJustify only apply to bcd packed fields, use Pad and Justify"},
+		{FieldDescription{ContentType: "n", LenType: "fixed", MaxLen: 6, BcdJustify: "left"}, "BcdPad and BcdJustify only apply to bcd packed fields, use Pad and Justify"},
+	}
+	for _, test := range tests {
+		_, err := Specs["1987"].Builder().Field(2, test.fieldDescription).Build()
+		if test.expected == "" {
+			if err != nil {
+				t.Errorf("expected %#v to be valid: %s", test.fieldDescription, err.Error())
+			}
+			continue
+		}
+		errs, ok := err.(SpecErrors)
+		if !ok || len(errs) != 1 || errs[0].Problem != test.expected {
+			t.Errorf("expected %q found %v", test.expected, err)
+		}
+	}
+}
@@ -0,0 +1,343 @@
+package iso8583
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/go-yaml/yaml"
+)
+
+// SpecFromJSON reads a spec laid out like a yaml spec file,
+// field numbers being the keys of a json object
+func SpecFromJSON(content []byte) (Spec, error) {
+	var raw interface{}
+	err := json.Unmarshal(content, &raw)
+	if err != nil {
+		return Spec{}, err
+	}
+	// json escapes such as \/ are not yaml ones, so the decoded value goes through yaml
+	out, err := yaml.Marshal(raw)
+	if err != nil {
+		return Spec{}, err
+	}
+	return SpecFromBytes(out)
+}
+
+// WriteYAML writes the spec out as a yaml spec file
+func (s Spec) WriteYAML(w io.Writer) error {
+	var out yaml.MapSlice
+	if s.header != "" {
+		out = append(out, yaml.MapItem{Key: "header", Value: s.header})
+	}
+	if s.headerLength > 0 {
+		out = append(out, yaml.MapItem{Key: "headerlength", Value: s.headerLength})
+	}
+	if len(s.presence) > 0 {
+		out = append(out, yaml.MapItem{Key: "presence", Value: s.presence})
+	}
+	for _, field := range s.Fields() {
+		out = append(out, yaml.MapItem{Key: field, Value: s.fields[field]})
+	}
+
+	content, err := yaml.Marshal(out)
+	if err != nil {
+		return err
+	}
+	_, err = w.Write(content)
+	return err
+}
+
+// jposPackager is the root of a jPOS GenericPackager file
+type jposPackager struct {
+	XMLName xml.Name    `xml:"isopackager"`
+	Fields  []jposField `xml:",any"`
+}
+
+// jposField is an isofield, or an isofieldpackager holding subfields
+type jposField struct {
+	XMLName    xml.Name
+	ID         string      `xml:"id,attr"`
+	Length     int         `xml:"length,attr"`
+	Name       string      `xml:"name,attr"`
+	Class      string      `xml:"class,attr"`
+	Packager   string      `xml:"packager,attr,omitempty"`
+	EmitBitmap string      `xml:"emitBitmap,attr,omitempty"`
+	Fields     []jposField `xml:",any"`
+}
+
+const (
+	jposClassPrefix     = "org.jpos.iso."
+	jposSubPackager     = "org.jpos.iso.packager.GenericSubFieldPackager"
+	jposDoctype         = `<!DOCTYPE isopackager SYSTEM "genericpackager.dtd">`
+	jposBitmapBytes     = 8
+	jposBitmapLength    = 16
+	jposFieldElement    = "isofield"
+	jposPackagerElement = "isofieldpackager"
+)
+
+// SpecFromJPOS reads a jPOS GenericPackager xml file, the IFA_, IFB_ and IFE_
+// field classes become text, binary and ebcdic field descriptions
+func SpecFromJPOS(content []byte) (Spec, error) {
+	var packager jposPackager
+	err := xml.Unmarshal(content, &packager)
+	if err != nil {
+		return Spec{}, err
+	}
+
+	b := NewSpecBuilder()
+	var errs SpecErrors
+	for _, f := range packager.Fields {
+		field, err := strconv.Atoi(f.ID)
+		if err != nil {
+			errs = append(errs, SpecError{Field: f.ID, Label: f.Name, Problem: "id should be a number"})
+			continue
+		}
+		fieldDescription, fieldErrs := jposToField(f, f.ID, field == 1)
+		if len(fieldErrs) > 0 {
+			errs = append(errs, fieldErrs...)
+			continue
+		}
+		b.Field(field, fieldDescription)
+	}
+	if len(errs) > 0 {
+		return Spec{}, errs
+	}
+	return b.Build()
+}
+
+// jposToField maps a jPOS field class onto a field description
+func jposToField(f jposField, field string, bitmap bool) (FieldDescription, SpecErrors) {
+	fieldDescription := FieldDescription{Label: f.Name, MaxLen: f.Length}
+	problem := func(format string, args ...interface{}) SpecErrors {
+		return SpecErrors{{Field: field, Label: f.Name, Problem: fmt.Sprintf(format, args...)}}
+	}
+
+	class := strings.TrimPrefix(f.Class, jposClassPrefix)
+	underscore := strings.Index(class, "_")
+	if underscore < 0 {
+		return fieldDescription, problem("%s is an unsupported class", f.Class)
+	}
+	switch class[:underscore] {
+	case "IF", "IFA":
+	case "IFB":
+		fieldDescription.HeaderHex = true
+	case "IFE":
+		fieldDescription.Encoding = "ebcdic"
+	default:
+		return fieldDescription, problem("%s is an unsupported class", f.Class)
+	}
+
+	// the number of Ls gives the digits of the length prefix, an H says it is binary
+	kind := strings.TrimLeft(class[underscore+1:], "L")
+	digits := len(class[underscore+1:]) - len(kind)
+	binaryLength := strings.HasPrefix(kind, "H") && kind != "HEX"
+	if binaryLength {
+		kind = kind[1:]
+	}
+	switch {
+	case digits == 0:
+		fieldDescription.LenType = "fixed"
+	case binaryLength && fieldDescription.HeaderHex && (digits == 2 || digits == 3):
+		fieldDescription.LenType = strings.Repeat("l", digits-1) + "bin"
+	case !binaryLength && digits >= 2 && digits <= 4:
+		fieldDescription.LenType = strings.Repeat("l", digits) + "var"
+	default:
+		return fieldDescription, problem("%s is an unsupported class", f.Class)
+	}
+	variable := digits > 0
+
+	switch kind {
+	case "NUMERIC", "NUM":
+		fieldDescription.ContentType = "n"
+	case "AMOUNT":
+		if fieldDescription.HeaderHex || variable {
+			return fieldDescription, problem("%s is an unsupported class", f.Class)
+		}
+		fieldDescription.ContentType = "x+n"
+	case "CHAR":
+		fieldDescription.ContentType = "ans"
+		if fieldDescription.HeaderHex && !variable {
+			// fixed characters go out as they are whatever the prefix
+			fieldDescription.HeaderHex = false
+		}
+		if fieldDescription.HeaderHex && !binaryLength {
+			fieldDescription.Contain = "string"
+		}
+	case "BINARY":
+		fieldDescription.ContentType = "b"
+		if !variable {
+			// fixed binary fields are described in hex digits
+			fieldDescription.MaxLen = f.Length * 2
+		} else if fieldDescription.HeaderHex && !binaryLength {
+			fieldDescription.Contain = "string"
+		}
+	case "BITMAP":
+		if variable {
+			return fieldDescription, problem("%s is an unsupported class", f.Class)
+		}
+		fieldDescription.ContentType = "b"
+		fieldDescription.MaxLen = jposBitmapBytes
+	default:
+		return fieldDescription, problem("%s is an unsupported class", f.Class)
+	}
+	if bitmap && kind != "BITMAP" {
+		return fieldDescription, problem("expected a bitmap class found %s", f.Class)
+	}
+
+	if f.XMLName.Local == jposPackagerElement {
+		if f.EmitBitmap == "true" {
+			fieldDescription.SubfieldFormat = "bitmap"
+		}
+		var errs SpecErrors
+		for _, sub := range f.Fields {
+			id := sub.ID
+			subBitmap := strings.HasSuffix(sub.Class, "_BITMAP")
+			if subBitmap {
+				// the bitmap of a bitmap formatted field is its subfield 1
+				id = "1"
+			}
+			subfield, subErrs := jposToField(sub, field+"."+id, subBitmap)
+			if len(subErrs) > 0 {
+				errs = append(errs, subErrs...)
+				continue
+			}
+			subfield.ID = id
+			fieldDescription.Subfields = append(fieldDescription.Subfields, subfield)
+		}
+		if len(errs) > 0 {
+			return fieldDescription, errs
+		}
+	}
+	return fieldDescription, nil
+}
+
+// WriteJPOS writes the spec out as a jPOS GenericPackager xml file,
+// fields that have no jPOS class are reported together
+func (s Spec) WriteJPOS(w io.Writer) error {
+	packager := jposPackager{}
+	var errs SpecErrors
+	for _, field := range s.Fields() {
+		f, fieldErrs := fieldToJPOS(s.fields[field], strconv.Itoa(field), field == 1)
+		if len(fieldErrs) > 0 {
+			errs = append(errs, fieldErrs...)
+			continue
+		}
+		packager.Fields = append(packager.Fields, f)
+	}
+	if len(errs) > 0 {
+		return errs
+	}
+
+	content, err := xml.MarshalIndent(packager, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = io.WriteString(w, xml.Header+jposDoctype+"\n"+string(content)+"\n")
+	return err
+}
+
+// fieldToJPOS picks the jPOS class matching a field description
+func fieldToJPOS(fieldDescription FieldDescription, field string, bitmap bool) (jposField, SpecErrors) {
+	f := jposField{XMLName: xml.Name{Local: jposFieldElement}, ID: field, Length: fieldDescription.MaxLen, Name: fieldDescription.Label}
+	problem := func(format string, args ...interface{}) SpecErrors {
+		return SpecErrors{{Field: field, Label: fieldDescription.Label, Problem: fmt.Sprintf(format, args...)}}
+	}
+	if i := strings.LastIndex(field, "."); i >= 0 {
+		f.ID = field[i+1:]
+	}
+
+	prefix := "IFA_"
+	if fieldDescription.HeaderHex {
+		prefix = "IFB_"
+	} else if fieldDescription.Encoding != "" && fieldDescription.Encoding != "ascii" {
+		prefix = "IFE_"
+	}
+
+	var class string
+	lenType := fieldDescription.LenType
+	binaryLength, isBinaryLength := getBinaryLengthFromString(lenType)
+	switch {
+	case bitmap:
+		class = prefix + "BITMAP"
+		f.Length = jposBitmapLength
+	case lenType == "fixed":
+		switch fieldDescription.ContentType {
+		case "n":
+			class = prefix + "NUMERIC"
+		case "x+n":
+			if fieldDescription.HeaderHex {
+				return f, problem("x+n has no binary jPOS class")
+			}
+			class = prefix + "AMOUNT"
+		case "b":
+			class = "IFB_BINARY"
+			if fieldDescription.HeaderHex {
+				f.Length = fieldDescription.MaxLen / 2
+			}
+		default:
+			if fieldDescription.HeaderHex {
+				return f, problem("%s held as hex has no jPOS class", fieldDescription.ContentType)
+			}
+			class = prefix + "CHAR"
+		}
+	case isBinaryLength:
+		if !fieldDescription.HeaderHex {
+			return f, problem("%s has no text jPOS class", lenType)
+		}
+		length := strings.Repeat("L", binaryLength+1) + "H"
+		if fieldDescription.ContentType == "b" {
+			class = prefix + length + "BINARY"
+		} else {
+			class = prefix + length + "CHAR"
+		}
+	default:
+		digits, err := getVariableLengthFromString(lenType)
+		if err != nil || digits > 4 {
+			return f, problem("%s has no jPOS class", lenType)
+		}
+		length := strings.Repeat("L", int(digits))
+		inBytes := fieldDescription.Contain == "string" || fieldDescription.Contain == "chip-tag"
+		switch {
+		case fieldDescription.ContentType == "n" || fieldDescription.ContentType == "z":
+			class = prefix + length + "NUM"
+		case fieldDescription.HeaderHex && !inBytes:
+			return f, problem("hex digit lengths have no jPOS class")
+		case fieldDescription.ContentType == "b" || fieldDescription.Contain == "chip-tag":
+			class = prefix + length + "BINARY"
+		default:
+			class = prefix + length + "CHAR"
+		}
+	}
+	f.Class = jposClassPrefix + class
+
+	if len(fieldDescription.Subfields) > 0 {
+		switch fieldDescription.SubfieldFormat {
+		case "", "position":
+		case "bitmap":
+			f.EmitBitmap = "true"
+		default:
+			return f, problem("%s subfields have no jPOS packager", fieldDescription.SubfieldFormat)
+		}
+		f.XMLName.Local = jposPackagerElement
+		f.Packager = jposSubPackager
+
+		var errs SpecErrors
+		for i, subfield := range fieldDescription.Subfields {
+			id := subfieldID(subfield, i)
+			sub, subErrs := fieldToJPOS(subfield, field+"."+id, fieldDescription.SubfieldFormat == "bitmap" && id == "1")
+			if len(subErrs) > 0 {
+				errs = append(errs, subErrs...)
+				continue
+			}
+			f.Fields = append(f.Fields, sub)
+		}
+		if len(errs) > 0 {
+			return f, errs
+		}
+	}
+	return f, nil
+}
@@ -0,0 +1,159 @@
+package iso8583
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const jposSample = `<?xml version="1.0" encoding="UTF-8" standalone="no"?>
+<!DOCTYPE isopackager SYSTEM "genericpackager.dtd">
+<isopackager>
+  <isofield id="0" length="4" name="MESSAGE TYPE INDICATOR" class="org.jpos.iso.IFB_NUMERIC"/>
+  <isofield id="1" length="16" name="BIT MAP" class="org.jpos.iso.IFB_BITMAP"/>
+  <isofield id="2" length="19" name="PAN - PRIMARY ACCOUNT NUMBER" class="org.jpos.iso.IFB_LLNUM"/>
+  <isofield id="28" length="9" name="AMOUNT, TRANSACTION FEE" class="org.jpos.iso.IFA_AMOUNT"/>
+  <isofield id="41" length="8" name="CARD ACCEPTOR TERMINAL IDENTIFICACION" class="org.jpos.iso.IF_CHAR"/>
+  <isofield id="44" length="25" name="ADITIONAL RESPONSE DATA" class="org.jpos.iso.IFE_LLCHAR"/>
+  <isofield id="52" length="8" name="PIN DATA" class="org.jpos.iso.IFB_BINARY"/>
+  <isofield id="55" length="255" name="ICC DATA" class="org.jpos.iso.IFB_LLLBINARY"/>
+  <isofield id="62" length="255" name="RESERVED PRIVATE" class="org.jpos.iso.IFB_LLHBINARY"/>
+  <isofieldpackager id="127" length="999" name="POSTILION PRIVATE" class="org.jpos.iso.IFA_LLLCHAR" packager="org.jpos.iso.packager.GenericSubFieldPackager" emitBitmap="true">
+    <isofield id="0" length="16" name="BIT MAP" class="org.jpos.iso.IFA_BITMAP"/>
+    <isofield id="2" length="32" name="SWITCH KEY" class="org.jpos.iso.IFA_LLCHAR"/>
+    <isofield id="3" length="48" name="ROUTING INFORMATION" class="org.jpos.iso.IF_CHAR"/>
+  </isofieldpackager>
+</isopackager>
+`
+
+func TestSpecFromJPOS(t *testing.T) {
+	spec, err := SpecFromJPOS([]byte(jposSample))
+	if err != nil {
+		t.Fatalf("failed to read jpos packager: %s", err.Error())
+	}
+
+	tests := []struct {
+		field    int
+		expected FieldDescription
+	}{
+		{0, FieldDescription{Label: "MESSAGE TYPE INDICATOR", ContentType: "n", LenType: "fixed", MaxLen: 4, HeaderHex: true}},
+		{1, FieldDescription{Label: "BIT MAP", ContentType: "b", LenType: "fixed", MaxLen: 8, HeaderHex: true}},
+		{2, FieldDescription{Label: "PAN - PRIMARY ACCOUNT NUMBER", ContentType: "n", LenType: "llvar", MaxLen: 19, HeaderHex: true}},
+		{28, FieldDescription{Label: "AMOUNT, TRANSACTION FEE", ContentType: "x+n", LenType: "fixed", MaxLen: 9}},
+		{41, FieldDescription{Label: "CARD ACCEPTOR TERMINAL IDENTIFICACION", ContentType: "ans", LenType: "fixed", MaxLen: 8}},
+		{44, FieldDescription{Label: "ADITIONAL RESPONSE DATA", ContentType: "ans", LenType: "llvar", MaxLen: 25, Encoding: "ebcdic"}},
+		{52, FieldDescription{Label: "PIN DATA", ContentType: "b", LenType: "fixed", MaxLen: 16, HeaderHex: true}},
+		{55, FieldDescription{Label: "ICC DATA", ContentType: "b", LenType: "lllvar", MaxLen: 255, HeaderHex: true, Contain: "string"}},
+		{62, FieldDescription{Label: "RESERVED PRIVATE", ContentType: "b", LenType: "lbin", MaxLen: 255, HeaderHex: true}},
+		{127, FieldDescription{Label: "POSTILION PRIVATE", ContentType: "ans", LenType: "lllvar", MaxLen: 999, SubfieldFormat: "bitmap", Subfields: []FieldDescription{
+			{ID: "1", Label: "BIT MAP", ContentType: "b", LenType: "fixed", MaxLen: 8},
+			{ID: "2", Label: "SWITCH KEY", ContentType: "ans", LenType: "llvar", MaxLen: 32},
+			{ID: "3", Label: "ROUTING INFORMATION", ContentType: "ans", LenType: "fixed", MaxLen: 48},
+		}}},
+	}
+	for _, test := range tests {
+		fieldDescription, ok := spec.Field(test.field)
+		if !ok || !reflect.DeepEqual(fieldDescription, test.expected) {
+			t.Errorf("field %d: expected %#v found %#v", test.field, test.expected, fieldDescription)
+		}
+	}
+
+	_, err = SpecFromJPOS([]byte(`<isopackager>
+  <isofield id="0" length="4" name="MTI" class="org.jpos.iso.IFA_NUMERIC"/>
+  <isofield id="1" length="16" name="BITMAP" class="org.jpos.iso.IFA_NUMERIC"/>
+  <isofield id="2" length="19" name="PAN" class="org.jpos.iso.IFX_LLNUM"/>
+  <isofield id="3" length="6" name="PROCESSING CODE" class="org.jpos.iso.IFB_LLLLLNUM"/>
+</isopackager>`))
+	errs, ok := err.(SpecErrors)
+	if !ok || len(errs) != 3 {
+		t.Errorf("expected a problem with fields 1, 2 and 3 found %v", err)
+	}
+}
+
+func TestWriteJPOS(t *testing.T) {
+	spec, _ := SpecFromJPOS([]byte(jposSample))
+	var first bytes.Buffer
+	err := spec.WriteJPOS(&first)
+	if err != nil {
+		t.Fatalf("failed to write jpos packager: %s", err.Error())
+	}
+	if !strings.Contains(first.String(), `<isofield id="52" length="8" name="PIN DATA" class="org.jpos.iso.IFB_BINARY"></isofield>`) {
+		t.Errorf("expected field 52 as IFB_BINARY of 8 bytes in\n%s", first.String())
+	}
+
+	again, err := SpecFromJPOS(first.Bytes())
+	if err != nil {
+		t.Fatalf("failed to read written jpos packager: %s", err.Error())
+	}
+	if !reflect.DeepEqual(again, spec) {
+		t.Errorf("spec changed going through jpos xml")
+	}
+
+	pos, _ := SpecFromFile("spec1987pos.yml")
+	var out bytes.Buffer
+	err = pos.WriteJPOS(&out)
+	if err != nil {
+		t.Fatalf("failed to write spec1987pos.yml as jpos: %s", err.Error())
+	}
+	if !strings.Contains(out.String(), `class="org.jpos.iso.IFB_LLLBINARY"`) {
+		t.Errorf("expected the chip data field as IFB_LLLBINARY")
+	}
+
+	tlv := Spec{fields: map[int]FieldDescription{48: {ContentType: "ans", LenType: "lllvar", MaxLen: 999, SubfieldFormat: "tlv", Subfields: []FieldDescription{{ID: "01"}}}}}
+	err = tlv.WriteJPOS(&out)
+	if err == nil {
+		t.Errorf("expected tlv subfields to have no jpos equivalent")
+	}
+}
+
+func TestSpecFromJSON(t *testing.T) {
+	content := `{
+  "header": "tpdu",
+  "0": {"ContentType": "n", "LenType": "fixed", "MaxLen": 4, "HeaderHex": true},
+  "1": {"ContentType": "b", "LenType": "fixed", "MaxLen": 8, "HeaderHex": true},
+  "2": {"ContentType": "n", "LenType": "llvar", "MaxLen": 19, "MinLen": 12, "Label": "Primary account number (PAN)"}
+}`
+	spec, err := SpecFromJSON([]byte(content))
+	if err != nil {
+		t.Fatalf("failed to read json spec: %s", err.Error())
+	}
+	pan, _ := spec.Field(2)
+	if pan.LenType != "llvar" || pan.MinLen != 12 || pan.Label != "Primary account number (PAN)" {
+		t.Errorf("unexpected field 2 %#v", pan)
+	}
+	if header, _ := spec.Header(); header != (Tpdu{}) {
+		t.Errorf("expected a tpdu header found %#v", header)
+	}
+
+	escaped, err := SpecFromJSON([]byte(`{"extends": "1987", "43": {"Label": "Card acceptor name\/location \u00e9"}}`))
+	if err != nil {
+		t.Fatalf("failed to read json with escapes: %s", err.Error())
+	}
+	if name, _ := escaped.Field(43); name.Label != "Card acceptor name/location \u00e9" || name.MaxLen != 40 {
+		t.Errorf("unexpected field 43 %#v", name)
+	}
+
+	_, err = SpecFromJSON([]byte("0:\n  LenType: fixed\n"))
+	if err == nil {
+		t.Errorf("expected yaml to be rejected as json")
+	}
+}
+
+func TestWriteYAML(t *testing.T) {
+	for _, filename := range []string{"spec1987.yml", "spec1987pos.yml"} {
+		spec, _ := SpecFromFile(filename)
+		var out bytes.Buffer
+		err := spec.WriteYAML(&out)
+		if err != nil {
+			t.Fatalf("%s: failed to write yaml: %s", filename, err.Error())
+		}
+		again, err := SpecFromBytes(out.Bytes())
+		if err != nil {
+			t.Fatalf("%s: failed to read written yaml: %s", filename, err.Error())
+		}
+		if !reflect.DeepEqual(again, spec) {
+			t.Errorf("%s: spec changed going through yaml", filename)
+		}
+	}
+}
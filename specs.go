@@ -0,0 +1,40 @@
+package iso8583
+
+import (
+	"embed"
+	"fmt"
+)
+
+//go:embed spec1987.yml spec1987pos.yml spec1987pos2.yml spec1987pos3.yml spec1993.yml spec2003.yml
+var specFiles embed.FS
+
+// Specs holds named specs ready to build messages from,
+// the ones shipped with the package are registered by version
+var Specs = map[string]Spec{}
+
+func init() {
+	shipped := map[string]string{
+		"1987":     "spec1987.yml",
+		"1987pos":  "spec1987pos.yml",
+		"1987pos2": "spec1987pos2.yml",
+		"1987pos3": "spec1987pos3.yml",
+		"1993":     "spec1993.yml",
+		"2003":     "spec2003.yml",
+	}
+	for name, filename := range shipped {
+		spec, err := SpecFromFS(specFiles, filename)
+		if err != nil {
+			panic(fmt.Errorf("%s: %s", filename, err.Error()))
+		}
+		RegisterSpec(name, spec)
+	}
+}
+
+// RegisterSpec makes a spec available in Specs under name,
+// it is meant to be called from init and panics if the name is taken
+func RegisterSpec(name string, spec Spec) {
+	if _, ok := Specs[name]; ok {
+		panic(fmt.Errorf("spec %s is already registered", name))
+	}
+	Specs[name] = spec
+}
@@ -0,0 +1,58 @@
+package iso8583
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSpecs(t *testing.T) {
+	for _, name := range []string{"1987", "1987pos", "1987pos2", "1987pos3", "1993", "2003"} {
+		spec, ok := Specs[name]
+		if !ok {
+			t.Errorf("expected spec %s to be registered", name)
+			continue
+		}
+		if len(spec.fields) != 129 {
+			t.Errorf("expected spec %s to describe 129 fields found %d", name, len(spec.fields))
+		}
+	}
+
+	fromFile, _ := SpecFromFile("spec1987pos.yml")
+	if !reflect.DeepEqual(Specs["1987pos"], fromFile) {
+		t.Errorf("embedded spec 1987pos differs from spec1987pos.yml")
+	}
+
+	one := NewISOStructFromSpec(Specs["1993"], false)
+	one.AddMTI("1100")
+	one.AddField(24, "100")
+	one.AddField(39, "000")
+	packed, err := one.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	parsed := NewISOStructFromSpec(Specs["1993"], false)
+	err = parsed.Unpack(packed)
+	if err != nil {
+		t.Fatalf("failed to unpack: %s", err.Error())
+	}
+	if value, _ := parsed.GetField(24); value != "100" {
+		t.Errorf("expected function code 100 found %s", value)
+	}
+}
+
+func TestRegisterSpec(t *testing.T) {
+	spec, _ := SpecFromFile("spec1987.yml")
+	RegisterSpec("test-host", spec)
+	defer delete(Specs, "test-host")
+
+	if _, ok := Specs["test-host"]; !ok {
+		t.Errorf("expected test-host to be registered")
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Errorf("expected registering a taken name to panic")
+		}
+	}()
+	RegisterSpec("1987", spec)
+}
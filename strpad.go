@@ -1,6 +1,9 @@
 package iso8583
 
-import "strings"
+import (
+	"fmt"
+	"strings"
+)
 
 func leftPad(s string, length int, pad string) string {
 	if len(s) >= length {
@@ -9,3 +12,68 @@ func leftPad(s string, length int, pad string) string {
 	padding := strings.Repeat(pad, length-len(s))
 	return padding + s
 }
+
+func rightPad(s string, length int, pad string) string {
+	if len(s) >= length {
+		return s
+	}
+	padding := strings.Repeat(pad, length-len(s))
+	return s + padding
+}
+
+// fieldPadding returns the pad character and justification of a fixed field,
+// numbers are right justified with zeros and text is left justified with
+// spaces unless the spec says otherwise, binary fields are not padded
+func fieldPadding(fieldDescription FieldDescription) (string, string) {
+	pad, justify := fieldDescription.Pad, fieldDescription.Justify
+	switch fieldDescription.ContentType {
+	case "n":
+		if pad == "" {
+			pad = "0"
+		}
+		if justify == "" {
+			justify = "right"
+		}
+	case "b", "z":
+	default:
+		if pad == "" {
+			pad = " "
+		}
+		if justify == "" {
+			justify = "left"
+		}
+	}
+	return pad, justify
+}
+
+// trimPadNibble drops the pad nibble an odd length bcd value
+// of a fixed field may come with already in place
+func trimPadNibble(fieldDescription FieldDescription, data string) string {
+	maxLen := fieldDescription.MaxLen
+	if isBCD(fieldDescription) && maxLen%2 != 0 && len(data) == maxLen+1 {
+		return trimBCDPad(data, maxLen, fieldDescription.BcdJustify)
+	}
+	return data
+}
+
+// padField pads the value of a fixed field up to its MaxLen
+func padField(fieldDescription FieldDescription, data string) (string, error) {
+	maxLen := fieldDescription.MaxLen
+	data = trimPadNibble(fieldDescription, data)
+	if len(data) > maxLen {
+		return data, fmt.Errorf("value of length %d exceeds max length %d", len(data), maxLen)
+	}
+	// signed amounts keep their sign in front of the zero padded digits
+	if isSignedAmount(fieldDescription) && len(data) > 0 && (data[0] == 'C' || data[0] == 'D') {
+		return data[0:1] + leftPad(data[1:], maxLen-1, "0"), nil
+	}
+
+	pad, justify := fieldPadding(fieldDescription)
+	if pad == "" {
+		return data, nil
+	}
+	if justify == "left" {
+		return rightPad(data, maxLen, pad), nil
+	}
+	return leftPad(data, maxLen, pad), nil
+}
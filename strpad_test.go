@@ -16,3 +16,45 @@ func TestLeftPad(t *testing.T) {
 		}
 	}
 }
+
+func TestPadField(t *testing.T) {
+	ts := []struct {
+		fieldDescription FieldDescription
+		data, expected   string
+	}{
+		{FieldDescription{ContentType: "n", MaxLen: 12}, "1500", "000000001500"},
+		{FieldDescription{ContentType: "an", MaxLen: 8}, "1234", "1234    "},
+		{FieldDescription{ContentType: "ans", MaxLen: 15}, "SHOP", "SHOP           "},
+		{FieldDescription{ContentType: "ans", MaxLen: 6, Pad: "0", Justify: "right"}, "42", "000042"},
+		{FieldDescription{ContentType: "n", MaxLen: 6, Pad: " ", Justify: "left"}, "42", "42    "},
+		{FieldDescription{ContentType: "b", MaxLen: 16}, "f9ff7fa3", "f9ff7fa3"},
+		{FieldDescription{ContentType: "n", MaxLen: 3, HeaderHex: true}, "0051", "051"},
+		{FieldDescription{ContentType: "n", MaxLen: 3, HeaderHex: true}, "51", "051"},
+	}
+	for _, v := range ts {
+		padded, err := padField(v.fieldDescription, v.data)
+		if err != nil {
+			t.Errorf("failed to pad %q: %s", v.data, err.Error())
+			continue
+		}
+		if padded != v.expected {
+			t.Errorf("Expected %q but got %q", v.expected, padded)
+		}
+	}
+
+	_, err := padField(FieldDescription{ContentType: "n", MaxLen: 4}, "12345")
+	if err == nil {
+		t.Errorf("should throw an error on values longer than MaxLen")
+	}
+
+	// the length check accepts the odd bcd values padding accepts
+	odd := FieldDescription{ContentType: "n", LenType: "fixed", MaxLen: 3, HeaderHex: true}
+	for _, data := range []string{"0051", "51"} {
+		if err = checkLength(odd, data); err != nil {
+			t.Errorf("expected %q to pass the length check: %s", data, err.Error())
+		}
+	}
+	if err = checkLength(odd, "10051"); err == nil {
+		t.Errorf("should refuse an odd bcd value longer than its pad nibble allows")
+	}
+}
@@ -0,0 +1,479 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// subfieldID returns the id a subfield is known by,
+// its position (starting at 1) when the spec gives it no ID
+func subfieldID(fieldDescription FieldDescription, index int) string {
+	if fieldDescription.ID != "" {
+		return fieldDescription.ID
+	}
+	return strconv.Itoa(index + 1)
+}
+
+// subfieldTagLen returns the length of the tags of a tlv formatted field
+func subfieldTagLen(fieldDescription FieldDescription) int {
+	if fieldDescription.TagLen > 0 {
+		return fieldDescription.TagLen
+	}
+	return 2
+}
+
+// findSubfield returns the description of the subfield known by id
+func findSubfield(fieldDescription FieldDescription, id string) (FieldDescription, bool) {
+	for index, sub := range fieldDescription.Subfields {
+		if subfieldID(sub, index) == id {
+			return sub, true
+		}
+	}
+	return FieldDescription{}, false
+}
+
+// splitSubfieldKey splits a key such as 48.2 or 127.22.1 into the field number
+// and the ids of the subfields leading to the value
+func splitSubfieldKey(key string) (int64, []string, error) {
+	parts := strings.Split(key, ".")
+	if len(parts) < 2 {
+		return 0, nil, fmt.Errorf("%s is not a subfield", key)
+	}
+	field, err := strconv.ParseInt(parts[0], 10, 64)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%s is not a subfield", key)
+	}
+	return field, parts[1:], nil
+}
+
+// fieldBytes returns the bytes a field value stands for
+func fieldBytes(fieldDescription FieldDescription, value string) (string, error) {
+	if holdsHex(fieldDescription) {
+		raw, err := hex.DecodeString(value)
+		return string(raw), err
+	}
+	return value, nil
+}
+
+// fieldValue is the counterpart of fieldBytes
+func fieldValue(fieldDescription FieldDescription, raw string) string {
+	if holdsHex(fieldDescription) {
+		return hex.EncodeToString([]byte(raw))
+	}
+	return raw
+}
+
+// hasSubfield reports whether key or any subfield below it has a value
+func hasSubfield(values map[string]string, key string) bool {
+	if _, ok := values[key]; ok {
+		return true
+	}
+	return hasChildren(values, key)
+}
+
+// hasChildren reports whether any subfield below key has a value
+func hasChildren(values map[string]string, key string) bool {
+	for k := range values {
+		if strings.HasPrefix(k, key+".") {
+			return true
+		}
+	}
+	return false
+}
+
+// unpackSubfields splits the bytes of a field into its subfields, the values
+// are stored under prefix followed by the subfield id, subfields with
+// subfields of their own are split as well
+func unpackSubfields(fieldDescription FieldDescription, prefix string, raw string, values map[string]string) error {
+	switch fieldDescription.SubfieldFormat {
+	case "", "position":
+		for index, sub := range fieldDescription.Subfields {
+			// trailing subfields may be left out
+			if len(raw) == 0 {
+				break
+			}
+			key := prefix + subfieldID(sub, index)
+			value, rest, err := extractField(sub, key, raw)
+			if err != nil {
+				return err
+			}
+			err = unpackSubfield(sub, key, value, values)
+			if err != nil {
+				return err
+			}
+			raw = rest
+		}
+	case "tlv":
+		tagLen := subfieldTagLen(fieldDescription)
+		for len(raw) > 0 {
+			if len(raw) < tagLen {
+				return fmt.Errorf("field %s: could not slice %d string of %d", strings.TrimSuffix(prefix, "."), len(raw), tagLen)
+			}
+			tag := raw[0:tagLen]
+			sub, ok := findSubfield(fieldDescription, tag)
+			if !ok {
+				return fmt.Errorf("field %s: unknown subfield tag %q", strings.TrimSuffix(prefix, "."), tag)
+			}
+			value, rest, err := extractField(sub, prefix+tag, raw[tagLen:])
+			if err != nil {
+				return err
+			}
+			err = unpackSubfield(sub, prefix+tag, value, values)
+			if err != nil {
+				return err
+			}
+			raw = rest
+		}
+	case "bitmap":
+		bitmap, rest, err := extractSubfieldBitmap(fieldDescription, prefix, raw)
+		if err != nil {
+			return err
+		}
+		values[prefix+"1"], _ = BitMapArrayToHex(bitmap)
+		raw = rest
+		for index := 1; index < len(bitmap); index++ {
+			if bitmap[index] != 1 {
+				continue
+			}
+			id := strconv.Itoa(index + 1)
+			sub, ok := findSubfield(fieldDescription, id)
+			if !ok {
+				return fmt.Errorf("spec error: field %s%s is not described by the spec", prefix, id)
+			}
+			value, rest, err := extractField(sub, prefix+id, raw)
+			if err != nil {
+				return err
+			}
+			err = unpackSubfield(sub, prefix+id, value, values)
+			if err != nil {
+				return err
+			}
+			raw = rest
+		}
+	default:
+		return fmt.Errorf("spec error: field %s: %s is an invalid SubfieldFormat", strings.TrimSuffix(prefix, "."), fieldDescription.SubfieldFormat)
+	}
+
+	if len(raw) > 0 {
+		return fmt.Errorf("field %s: %d bytes left over after the last subfield", strings.TrimSuffix(prefix, "."), len(raw))
+	}
+	return nil
+}
+
+// unpackSubfield stores the value of a subfield and splits it further when it has subfields
+func unpackSubfield(fieldDescription FieldDescription, key string, value string, values map[string]string) error {
+	values[key] = value
+	if len(fieldDescription.Subfields) == 0 {
+		return nil
+	}
+	raw, err := fieldBytes(fieldDescription, value)
+	if err != nil {
+		return fmt.Errorf("field %s: %s", key, err.Error())
+	}
+	return unpackSubfields(fieldDescription, key+".", raw, values)
+}
+
+// packSubfields rebuilds the bytes of a field from the values of its subfields
+func packSubfields(fieldDescription FieldDescription, prefix string, values map[string]string) (string, error) {
+	var raw string
+	switch fieldDescription.SubfieldFormat {
+	case "", "position":
+		// subfields are positional so the ones before the last present one are always sent
+		last := -1
+		for index, sub := range fieldDescription.Subfields {
+			if hasSubfield(values, prefix+subfieldID(sub, index)) {
+				last = index
+			}
+		}
+		for index := 0; index <= last; index++ {
+			sub := fieldDescription.Subfields[index]
+			data, err := packSubfield(sub, prefix+subfieldID(sub, index), values)
+			if err != nil {
+				return raw, err
+			}
+			raw = raw + data
+		}
+	case "tlv":
+		tagLen := subfieldTagLen(fieldDescription)
+		for index, sub := range fieldDescription.Subfields {
+			tag := subfieldID(sub, index)
+			if !hasSubfield(values, prefix+tag) {
+				continue
+			}
+			if len(tag) != tagLen {
+				return raw, fmt.Errorf("spec error: field %s: tag %q is not %d long", prefix+tag, tag, tagLen)
+			}
+			data, err := packSubfield(sub, prefix+tag, values)
+			if err != nil {
+				return raw, err
+			}
+			raw = raw + tag + data
+		}
+	case "bitmap":
+		// the bitmap is rebuilt from whichever subfields are set
+		bitmap := make([]int64, 64)
+		var data string
+		for index := 1; index < len(bitmap); index++ {
+			id := strconv.Itoa(index + 1)
+			if !hasSubfield(values, prefix+id) {
+				continue
+			}
+			sub, ok := findSubfield(fieldDescription, id)
+			if !ok {
+				return raw, fmt.Errorf("spec error: field %s%s is not described by the spec", prefix, id)
+			}
+			subData, err := packSubfield(sub, prefix+id, values)
+			if err != nil {
+				return raw, err
+			}
+			bitmap[index] = 1
+			data = data + subData
+		}
+		bitmapHex, _ := BitMapArrayToHex(bitmap)
+		values[prefix+"1"] = bitmapHex
+		bitmapData, err := packSubfieldBitmap(fieldDescription, bitmapHex)
+		if err != nil {
+			return raw, err
+		}
+		raw = bitmapData + data
+	default:
+		return raw, fmt.Errorf("spec error: field %s: %s is an invalid SubfieldFormat", strings.TrimSuffix(prefix, "."), fieldDescription.SubfieldFormat)
+	}
+	return raw, nil
+}
+
+// subfieldBitmapDescription returns how the bitmap of a bitmap formatted field
+// is sent, as described by subfield 1 or as 8 binary bytes when it is not described
+func subfieldBitmapDescription(fieldDescription FieldDescription) FieldDescription {
+	if sub, ok := findSubfield(fieldDescription, "1"); ok {
+		return sub
+	}
+	return FieldDescription{ContentType: "b", LenType: "fixed", MaxLen: 8, HeaderHex: true}
+}
+
+// extractSubfieldBitmap reads the 64 bit bitmap in front of the subfields of
+// a bitmap formatted field, unlike the message bitmap it is never extended
+func extractSubfieldBitmap(fieldDescription FieldDescription, prefix string, raw string) ([]int64, string, error) {
+	bitmapDescription := subfieldBitmapDescription(fieldDescription)
+	length := 16
+	if bitmapDescription.HeaderHex {
+		length = 8
+	}
+	if len(raw) < length {
+		return nil, raw, fmt.Errorf("field %s1: could not slice %d string of %d", prefix, len(raw), length)
+	}
+
+	var bitmapHex string
+	if bitmapDescription.HeaderHex {
+		bitmapHex = hex.EncodeToString([]byte(raw[0:length]))
+	} else {
+		var err error
+		bitmapHex, err = decodeText(bitmapDescription.Encoding, raw[0:length])
+		if err != nil {
+			return nil, raw, fmt.Errorf("spec error: field %s1: %s", prefix, err.Error())
+		}
+	}
+	bitmap, err := HexToBitmapArray(bitmapHex)
+	if err != nil {
+		return nil, raw, fmt.Errorf("field %s1: %s", prefix, err.Error())
+	}
+	return bitmap, raw[length:], nil
+}
+
+// packSubfieldBitmap is the counterpart of extractSubfieldBitmap
+func packSubfieldBitmap(fieldDescription FieldDescription, bitmapHex string) (string, error) {
+	bitmapDescription := subfieldBitmapDescription(fieldDescription)
+	if bitmapDescription.HeaderHex {
+		raw, err := hex.DecodeString(bitmapHex)
+		return string(raw), err
+	}
+	return encodeText(bitmapDescription.Encoding, bitmapHex)
+}
+
+// packSubfield packs the value of one subfield, rebuilding it from its own
+// subfields first when it has any
+func packSubfield(fieldDescription FieldDescription, key string, values map[string]string) (string, error) {
+	if len(fieldDescription.Subfields) > 0 && hasChildren(values, key) {
+		raw, err := packSubfields(fieldDescription, key+".", values)
+		if err != nil {
+			return "", err
+		}
+		values[key] = fieldValue(fieldDescription, raw)
+	}
+	return packField(fieldDescription, key, values[key])
+}
+
+// loadSubfields returns the subfield values of a field,
+// splitting the field the first time they are asked for
+func (iso *IsoStruct) loadSubfields(field int64) (map[string]string, error) {
+	if values, ok := iso.subfields[field]; ok {
+		return values, nil
+	}
+	fieldDescription := iso.Spec.fields[int(field)]
+	if len(fieldDescription.Subfields) == 0 {
+		return nil, fmt.Errorf("field %d has no subfields", field)
+	}
+
+	values := make(map[string]string)
+	if data, ok := iso.Elements.elements[field]; ok {
+		raw, err := fieldBytes(fieldDescription, data)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %s", field, err.Error())
+		}
+		err = unpackSubfields(fieldDescription, strconv.FormatInt(field, 10)+".", raw, values)
+		if truncated, ok := err.(*TruncatedError); ok {
+			// the field itself was read whole, more input would not help
+			return nil, fmt.Errorf("field %s: overruns field %d: need %d bytes have %d", truncated.Field, field, truncated.Need, truncated.Have)
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if iso.subfields == nil {
+		iso.subfields = make(map[int64]map[string]string)
+	}
+	iso.subfields[field] = values
+	return values, nil
+}
+
+// GetSubfield returns the value of a subfield such as 48.2 or 63.TableID
+func (iso *IsoStruct) GetSubfield(key string) (string, error) {
+	field, _, err := splitSubfieldKey(key)
+	if err != nil {
+		return "", err
+	}
+	values, err := iso.loadSubfields(field)
+	if err != nil {
+		return "", err
+	}
+	data, ok := values[key]
+	if !ok {
+		return "", fmt.Errorf("field %s is not present", key)
+	}
+	return data, nil
+}
+
+// AddSubfield adds the provided subfield, the field holding it
+// is rebuilt from its subfields in the process
+func (iso *IsoStruct) AddSubfield(key string, data string) error {
+	field, ids, err := splitSubfieldKey(key)
+	if err != nil {
+		return err
+	}
+	fieldDescription := iso.Spec.fields[int(field)]
+	for _, id := range ids {
+		var ok bool
+		fieldDescription, ok = findSubfield(fieldDescription, id)
+		if !ok {
+			return fmt.Errorf("field %s is not described by the spec", key)
+		}
+	}
+
+	values, err := iso.loadSubfields(field)
+	if err != nil {
+		return err
+	}
+	previous, existed := values[key]
+	values[key] = data
+	err = iso.rebuildField(field, values)
+	if err != nil {
+		if existed {
+			values[key] = previous
+		} else {
+			delete(values, key)
+		}
+		return err
+	}
+	return nil
+}
+
+// RemoveSubfield removes the provided subfield, the field holding it
+// is rebuilt from the remaining subfields in the process
+func (iso *IsoStruct) RemoveSubfield(key string) error {
+	field, _, err := splitSubfieldKey(key)
+	if err != nil {
+		return err
+	}
+	values, err := iso.loadSubfields(field)
+	if err != nil {
+		return err
+	}
+	removed := make(map[string]string)
+	for k, value := range values {
+		if k == key || strings.HasPrefix(k, key+".") {
+			removed[k] = value
+			delete(values, k)
+		}
+	}
+	// subfields left without any of theirs hold a stale packed value
+	parts := strings.Split(key, ".")
+	for i := len(parts) - 1; i > 1; i-- {
+		parent := strings.Join(parts[:i], ".")
+		if value, ok := values[parent]; ok && !hasChildren(values, parent) {
+			removed[parent] = value
+			delete(values, parent)
+		}
+	}
+	dropEmptyBitmaps(iso.Spec.fields[int(field)], strconv.FormatInt(field, 10)+".", values, removed)
+	if len(values) == 0 {
+		return iso.RemoveField(field)
+	}
+	err = iso.rebuildField(field, values)
+	if err != nil {
+		for k, value := range removed {
+			values[k] = value
+		}
+		return err
+	}
+	return nil
+}
+
+// dropEmptyBitmaps removes the bitmaps of bitmap formatted fields left
+// without subfields, as they would otherwise be sent on their own
+func dropEmptyBitmaps(fieldDescription FieldDescription, prefix string, values map[string]string, removed map[string]string) {
+	for index, sub := range fieldDescription.Subfields {
+		if len(sub.Subfields) > 0 {
+			dropEmptyBitmaps(sub, prefix+subfieldID(sub, index)+".", values, removed)
+		}
+	}
+	if fieldDescription.SubfieldFormat != "bitmap" {
+		return
+	}
+	bitmap, ok := values[prefix+"1"]
+	if !ok {
+		return
+	}
+	for k := range values {
+		if strings.HasPrefix(k, prefix) && k != prefix+"1" {
+			return
+		}
+	}
+	removed[prefix+"1"] = bitmap
+	delete(values, prefix+"1")
+	if parent := strings.TrimSuffix(prefix, "."); strings.Contains(parent, ".") {
+		if value, ok := values[parent]; ok {
+			removed[parent] = value
+			delete(values, parent)
+		}
+	}
+}
+
+// rebuildField packs the subfield values back into their field,
+// the field goes through AddField so it is validated like any other
+func (iso *IsoStruct) rebuildField(field int64, values map[string]string) error {
+	fieldDescription := iso.Spec.fields[int(field)]
+	raw, err := packSubfields(fieldDescription, strconv.FormatInt(field, 10)+".", values)
+	if err != nil {
+		return err
+	}
+	err = iso.AddField(field, fieldValue(fieldDescription, raw))
+	if err != nil {
+		return err
+	}
+	// AddField drops the split values, they are the ones just packed
+	iso.subfields[field] = values
+	return nil
+}
@@ -0,0 +1,314 @@
+package iso8583
+
+import (
+	"testing"
+)
+
+var subfieldSpec = `
+0:
+  ContentType: "n"
+  LenType: fixed
+  MaxLen: 4
+1:
+  ContentType: "b"
+  LenType: fixed
+  MaxLen: 8
+48:
+  ContentType: ans
+  Label: Additional data - private
+  LenType: lllvar
+  MaxLen: 999
+  Subfields:
+    - ContentType: "n"
+      LenType: fixed
+      MaxLen: 2
+    - ContentType: ans
+      LenType: llvar
+      MaxLen: 25
+    - ContentType: "n"
+      LenType: fixed
+      MaxLen: 4
+62:
+  ContentType: ans
+  Label: Reserved private
+  LenType: lllvar
+  MaxLen: 999
+  SubfieldFormat: tlv
+  Subfields:
+    - ID: "01"
+      ContentType: ans
+      LenType: llvar
+      MaxLen: 20
+    - ID: "02"
+      ContentType: "n"
+      LenType: llvar
+      MaxLen: 12
+63:
+  ContentType: ans
+  Label: Reserved private
+  LenType: lllvar
+  MaxLen: 999
+  HeaderHex: true
+  Contain: string
+  Subfields:
+    - ID: TableID
+      ContentType: an
+      LenType: fixed
+      MaxLen: 2
+    - ID: Data
+      ContentType: ans
+      LenType: lllvar
+      MaxLen: 996
+`
+
+func loadSubfieldSpec(t *testing.T) Spec {
+	spec, err := SpecFromBytes([]byte(subfieldSpec))
+	if err != nil {
+		t.Fatalf("failed to read spec: %s", err.Error())
+	}
+	return spec
+}
+
+func TestParseSubfields(t *testing.T) {
+	spec := loadSubfieldSpec(t)
+	isomsg := "0800" + "0000000000010006" + "017" + "0213MERCHANT NAME" + "020" + "0109TERMINAL " + "0203100" + "\x00\x06" + "1F001X"
+
+	one := NewISOStructFromSpec(spec, false)
+	parsed, err := one.Parse(isomsg)
+	if err != nil {
+		t.Fatalf("parse iso message failed: %s", err.Error())
+	}
+
+	ts := []struct {
+		key, expected string
+	}{
+		{"48.1", "02"},
+		{"48.2", "MERCHANT NAME"},
+		{"62.01", "TERMINAL "},
+		{"62.02", "100"},
+		{"63.TableID", "1F"},
+		{"63.Data", "X"},
+	}
+	for _, v := range ts {
+		value, err := parsed.GetSubfield(v.key)
+		if err != nil {
+			t.Errorf("failed to get %s: %s", v.key, err.Error())
+			continue
+		}
+		if value != v.expected {
+			t.Errorf("%s: expected %q but got %q", v.key, v.expected, value)
+		}
+	}
+
+	if _, err := parsed.GetSubfield("48.3"); err == nil {
+		t.Errorf("trailing subfield 48.3 should not be present")
+	}
+}
+
+func TestBuildSubfields(t *testing.T) {
+	spec := loadSubfieldSpec(t)
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0800")
+
+	if err := one.AddSubfield("48.2", "SHOP"); err != nil {
+		t.Fatalf("failed to add subfield: %s", err.Error())
+	}
+	if err := one.AddSubfield("62.02", "42"); err != nil {
+		t.Fatalf("failed to add subfield: %s", err.Error())
+	}
+	if err := one.AddSubfield("62.01", "T1"); err != nil {
+		t.Fatalf("failed to add subfield: %s", err.Error())
+	}
+	if err := one.AddSubfield("63.TableID", "A1"); err != nil {
+		t.Fatalf("failed to add subfield: %s", err.Error())
+	}
+	if err := one.AddSubfield("63.Unknown", "A1"); err == nil {
+		t.Errorf("should throw an error on subfields missing from the spec")
+	}
+
+	if value, _ := one.GetField(48); value != "0004SHOP" {
+		t.Errorf("expected field 48 to be 0004SHOP found %s", value)
+	}
+	if value, _ := one.GetField(62); value != "0102T1020242" {
+		t.Errorf("expected field 62 to be 0102T1020242 found %s", value)
+	}
+	if value, _ := one.GetField(63); value != "4131" {
+		t.Errorf("expected field 63 to be 4131 found %s", value)
+	}
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack message with subfields: %s", err.Error())
+	}
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse message with subfields: %s", err.Error())
+	}
+	if value, _ := parsed.GetSubfield("62.01"); value != "T1" {
+		t.Errorf("expected 62.01 to be T1 found %s", value)
+	}
+
+	one.RemoveSubfield("48.2")
+	if _, ok := one.GetField(48); ok {
+		t.Errorf("field 48 should be removed along with its last subfield")
+	}
+}
+
+var nestedBitmapSpec = `
+0:
+  ContentType: "n"
+  LenType: fixed
+  MaxLen: 4
+1:
+  ContentType: "b"
+  LenType: fixed
+  MaxLen: 8
+127:
+  ContentType: ans
+  Label: Postilion private data
+  LenType: llllllvar
+  MaxLen: 999999
+  SubfieldFormat: bitmap
+  Subfields:
+    - ID: "2"
+      ContentType: ans
+      Label: Switch key
+      LenType: llvar
+      MaxLen: 32
+    - ID: "3"
+      ContentType: ans
+      Label: Routing information
+      LenType: fixed
+      MaxLen: 4
+    - ID: "22"
+      ContentType: ans
+      Label: Structured data
+      LenType: lllllvar
+      MaxLen: 99999
+    - ID: "25"
+      ContentType: ans
+      Label: ICC data
+      LenType: llllvar
+      MaxLen: 9999
+      SubfieldFormat: bitmap
+      Subfields:
+        - ID: "2"
+          ContentType: "n"
+          LenType: fixed
+          MaxLen: 4
+        - ID: "12"
+          ContentType: an
+          LenType: llvar
+          MaxLen: 16
+`
+
+func TestNestedBitmapSubfields(t *testing.T) {
+	spec, err := SpecFromBytes([]byte(nestedBitmapSpec))
+	if err != nil {
+		t.Fatalf("failed to read spec: %s", err.Error())
+	}
+	one := NewISOStructFromSpec(spec, true)
+	one.AddMTI("0200")
+
+	fields := []struct {
+		key, value string
+	}{
+		{"127.2", "0000123456"},
+		{"127.3", "ROUT"},
+		{"127.25.2", "0001"},
+		{"127.25.12", "ABCD"},
+	}
+	for _, v := range fields {
+		if err := one.AddSubfield(v.key, v.value); err != nil {
+			t.Fatalf("failed to add %s: %s", v.key, err.Error())
+		}
+	}
+
+	nested := "\x40\x10\x00\x00\x00\x00\x00\x00" + "0001" + "04ABCD"
+	expected := "\x60\x00\x00\x80\x00\x00\x00\x00" + "100000123456" + "ROUT" + "0018" + nested
+	if value, _ := one.GetField(127); value != expected {
+		t.Errorf("expected field 127 to be %q found %q", expected, value)
+	}
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack nested bitmap: %s", err.Error())
+	}
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse nested bitmap: %s", err.Error())
+	}
+	for _, v := range fields {
+		value, err := parsed.GetSubfield(v.key)
+		if err != nil || value != v.value {
+			t.Errorf("%s: expected %s but got %s", v.key, v.value, value)
+		}
+	}
+	if value, _ := parsed.GetSubfield("127.1"); value != "6000008000000000" {
+		t.Errorf("expected bitmap 127.1 to be 6000008000000000 found %s", value)
+	}
+
+	// a malformed nested message is reported by Parse
+	parsed.Elements.elements[127] = "\x60\x00\x00\x80\x00\x00\x00\x00" + "10000"
+	parsed.subfields = nil
+	packed, _ = parsed.ToString()
+	_, err = one.Parse(packed)
+	if err == nil {
+		t.Errorf("should throw an error on a malformed nested message")
+	}
+}
+
+func TestRebuiltFieldValidation(t *testing.T) {
+	additional, _ := loadSubfieldSpec(t).Field(48)
+	additional.MaxLen = 8
+	additional.Validators = []string{"luhn"}
+	spec, err := loadSubfieldSpec(t).Builder().Field(48, additional).Build()
+	if err != nil {
+		t.Fatalf("failed to build spec: %s", err.Error())
+	}
+	one := NewISOStructFromSpec(spec, false)
+
+	if err := one.AddSubfield("48.1", "18"); err != nil {
+		t.Fatalf("failed to add 48.1: %s", err.Error())
+	}
+	if err := one.AddSubfield("48.1", "19"); err == nil {
+		t.Errorf("expected field 48 failing the luhn check to be refused")
+	}
+	if err := one.AddSubfield("48.2", "ABCDEFGHIJ"); err == nil {
+		t.Errorf("expected field 48 beyond its max length to be refused")
+	}
+	if value, _ := one.GetField(48); value != "18" {
+		t.Errorf("expected field 48 to stay 18 found %q", value)
+	}
+	if value, _ := one.GetSubfield("48.1"); value != "18" {
+		t.Errorf("expected 48.1 to stay 18 found %q", value)
+	}
+	if _, err := one.GetSubfield("48.2"); err == nil {
+		t.Errorf("expected the refused 48.2 to be dropped")
+	}
+}
+
+func TestRemoveLastBitmapSubfield(t *testing.T) {
+	spec, err := SpecFromBytes([]byte(nestedBitmapSpec))
+	if err != nil {
+		t.Fatalf("failed to read spec: %s", err.Error())
+	}
+	one := NewISOStructFromSpec(spec, true)
+
+	one.AddSubfield("127.3", "ROUT")
+	one.AddSubfield("127.25.2", "0001")
+	if err := one.RemoveSubfield("127.25.2"); err != nil {
+		t.Fatalf("failed to remove 127.25.2: %s", err.Error())
+	}
+	expected := "\x20\x00\x00\x00\x00\x00\x00\x00" + "ROUT"
+	if value, _ := one.GetField(127); value != expected {
+		t.Errorf("expected 127.25 to go along with its last subfield, field 127 is %q", value)
+	}
+
+	if err := one.RemoveSubfield("127.3"); err != nil {
+		t.Fatalf("failed to remove 127.3: %s", err.Error())
+	}
+	if value, ok := one.GetField(127); ok || one.Bitmap[126] != 0 {
+		t.Errorf("expected field 127 to go along with its last subfield found %q", value)
+	}
+}
@@ -0,0 +1,226 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// TLV is a BER-TLV data object such as the EMV tags carried in field 55
+type TLV struct {
+	Tag      string // tag as upper case hex, e.g. 9F26
+	Value    []byte // value of a primitive tag
+	Children TLVList
+}
+
+// Constructed reports whether the tag holds other tags rather than a value
+func (t TLV) Constructed() bool {
+	tag, err := hex.DecodeString(t.Tag)
+	if err != nil || len(tag) == 0 {
+		return false
+	}
+	return tag[0]&0x20 != 0
+}
+
+// TLVList is an ordered list of BER-TLV data objects
+type TLVList []TLV
+
+// Get returns the first tag matching tag, nested tags are searched too
+func (l TLVList) Get(tag string) (TLV, bool) {
+	tag = strings.ToUpper(tag)
+	for _, t := range l {
+		if t.Tag == tag {
+			return t, true
+		}
+		if found, ok := t.Children.Get(tag); ok {
+			return found, true
+		}
+	}
+	return TLV{}, false
+}
+
+// Set replaces the value of a top level tag or appends it when missing
+func (l *TLVList) Set(tag string, value []byte) error {
+	tag = strings.ToUpper(tag)
+	raw, err := hex.DecodeString(tag)
+	if err != nil {
+		return fmt.Errorf("%s is an invalid tag", tag)
+	}
+	if _, rest, err := decodeTag(raw); err != nil || len(rest) != 0 {
+		return fmt.Errorf("%s is an invalid tag", tag)
+	}
+	for i := range *l {
+		if (*l)[i].Tag == tag {
+			(*l)[i].Value = value
+			(*l)[i].Children = nil
+			return nil
+		}
+	}
+	*l = append(*l, TLV{Tag: tag, Value: value})
+	return nil
+}
+
+// Remove removes a top level tag, it reports whether the tag was found
+func (l *TLVList) Remove(tag string) bool {
+	tag = strings.ToUpper(tag)
+	for i := range *l {
+		if (*l)[i].Tag == tag {
+			*l = append((*l)[:i], (*l)[i+1:]...)
+			return true
+		}
+	}
+	return false
+}
+
+// Encode packs the list back into BER-TLV bytes
+func (l TLVList) Encode() []byte {
+	var data []byte
+	for _, t := range l {
+		tag, _ := hex.DecodeString(t.Tag)
+		value := t.Value
+		if t.Constructed() && t.Children != nil {
+			value = t.Children.Encode()
+		}
+		data = append(data, tag...)
+		data = append(data, encodeTLVLength(len(value))...)
+		data = append(data, value...)
+	}
+	return data
+}
+
+// DecodeTLV decodes BER-TLV bytes into an ordered list, constructed tags
+// are decoded into their children and 00/FF padding between tags is skipped
+func DecodeTLV(data []byte) (TLVList, error) {
+	var list TLVList
+	for len(data) > 0 {
+		if data[0] == 0x00 || data[0] == 0xff {
+			data = data[1:]
+			continue
+		}
+
+		tag, rest, err := decodeTag(data)
+		if err != nil {
+			return list, err
+		}
+		length, rest, err := decodeTLVLength(rest)
+		if err != nil {
+			return list, fmt.Errorf("tag %X: %s", tag, err.Error())
+		}
+		if len(rest) < length {
+			return list, fmt.Errorf("tag %X: could not slice %d bytes of %d", tag, len(rest), length)
+		}
+
+		t := TLV{Tag: strings.ToUpper(hex.EncodeToString(tag)), Value: rest[0:length]}
+		if t.Constructed() {
+			t.Children, err = DecodeTLV(t.Value)
+			if err != nil {
+				return list, err
+			}
+			t.Value = nil
+		}
+		list = append(list, t)
+		data = rest[length:]
+	}
+	return list, nil
+}
+
+// decodeTag splits a tag off the front of data, tags whose low five bits are
+// all set continue for as long as the following bytes have their high bit set
+func decodeTag(data []byte) ([]byte, []byte, error) {
+	if len(data) < 1 {
+		return nil, data, fmt.Errorf("no tag to be processed")
+	}
+	size := 1
+	if data[0]&0x1f == 0x1f {
+		for {
+			if len(data) <= size {
+				return nil, data, fmt.Errorf("tag %X is truncated", data)
+			}
+			size++
+			if data[size-1]&0x80 == 0 {
+				break
+			}
+		}
+	}
+	return data[0:size], data[size:], nil
+}
+
+// decodeTLVLength reads a short (one byte) or long (81 to 84 followed by
+// up to four bytes) form length
+func decodeTLVLength(data []byte) (int, []byte, error) {
+	if len(data) < 1 {
+		return 0, data, fmt.Errorf("length is missing")
+	}
+	if data[0]&0x80 == 0 {
+		return int(data[0]), data[1:], nil
+	}
+	size := int(data[0] & 0x7f)
+	if size < 1 || size > 4 || len(data) < size+1 {
+		return 0, data, fmt.Errorf("invalid length %X", data[0])
+	}
+	var length int
+	for i := 1; i <= size; i++ {
+		length = length<<8 | int(data[i])
+	}
+	return length, data[size+1:], nil
+}
+
+func encodeTLVLength(length int) []byte {
+	if length < 0x80 {
+		return []byte{byte(length)}
+	}
+	var size []byte
+	for l := length; l > 0; l = l >> 8 {
+		size = append([]byte{byte(l)}, size...)
+	}
+	return append([]byte{0x80 | byte(len(size))}, size...)
+}
+
+// ChipData returns the tags held in a chip-tag field such as field 55,
+// changes made to the list are packed back into the field by Pack and ToString
+func (iso *IsoStruct) ChipData(field int64) (*TLVList, error) {
+	if list, ok := iso.chipData[field]; ok {
+		return list, nil
+	}
+	if iso.Spec.fields[int(field)].Contain != "chip-tag" {
+		return nil, fmt.Errorf("field %d does not contain chip tags", field)
+	}
+
+	list := &TLVList{}
+	if data, ok := iso.Elements.elements[field]; ok {
+		raw, err := hex.DecodeString(data)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %s", field, err.Error())
+		}
+		*list, err = DecodeTLV(raw)
+		if err != nil {
+			return nil, fmt.Errorf("field %d: %s", field, err.Error())
+		}
+	}
+
+	if iso.chipData == nil {
+		iso.chipData = make(map[int64]*TLVList)
+	}
+	iso.chipData[field] = list
+	return list, nil
+}
+
+// packChipData writes the tag lists handed out by ChipData back into their fields
+func (iso *IsoStruct) packChipData() error {
+	for field, list := range iso.chipData {
+		if len(*list) == 0 {
+			if _, ok := iso.Elements.elements[field]; ok {
+				iso.RemoveField(field)
+				iso.chipData[field] = list
+			}
+			continue
+		}
+		err := iso.AddField(field, hex.EncodeToString(list.Encode()))
+		if err != nil {
+			return err
+		}
+		// AddField and RemoveField drop the list, it stays handed out
+		iso.chipData[field] = list
+	}
+	return nil
+}
@@ -0,0 +1,173 @@
+package iso8583
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+	"testing"
+)
+
+func TestDecodeTLV(t *testing.T) {
+	data, _ := hex.DecodeString("5f2a020360820274009f2608921771745f33f43b" + "70" + "09" + "5a03123450" + "5f340101" + "0000" + "df8101" + "81" + "80" + strings.Repeat("ab", 128))
+	list, err := DecodeTLV(data)
+	if err != nil {
+		t.Fatalf("failed to decode valid tlv data: %s", err.Error())
+	}
+
+	tags := []string{"5F2A", "82", "9F26", "70", "DF8101"}
+	if len(list) != len(tags) {
+		t.Fatalf("expected %d tags found %d", len(tags), len(list))
+	}
+	for i, tag := range tags {
+		if list[i].Tag != tag {
+			t.Errorf("expected tag %s at %d found %s", tag, i, list[i].Tag)
+		}
+	}
+
+	if !list[3].Constructed() || len(list[3].Children) != 2 {
+		t.Errorf("expected tag 70 to be constructed with 2 children found %#v", list[3])
+	}
+	pan, ok := list.Get("5a")
+	if !ok || hex.EncodeToString(pan.Value) != "123450" {
+		t.Errorf("expected nested tag 5A to be 123450 found %x", pan.Value)
+	}
+	if len(list[4].Value) != 128 {
+		t.Errorf("expected a long form length of 128 found %d", len(list[4].Value))
+	}
+
+	// padding between tags is dropped on the way back
+	expected := bytes.Replace(data, []byte{0x00, 0x00}, nil, 1)
+	if !bytes.Equal(list.Encode(), expected) {
+		t.Errorf("%x should be %x", list.Encode(), expected)
+	}
+
+	_, err = DecodeTLV([]byte{0x9f, 0x26, 0x08, 0x01})
+	if err == nil {
+		t.Errorf("should throw an error on truncated tlv data")
+	}
+}
+
+func TestTLVListEdit(t *testing.T) {
+	var list TLVList
+	list.Set("9f02", []byte{0x00, 0x00, 0x00, 0x00, 0x15, 0x00})
+	list.Set("9C", []byte{0x00})
+	list.Set("9F02", []byte{0x00, 0x00, 0x00, 0x00, 0x20, 0x00})
+
+	if hex.EncodeToString(list.Encode()) != "9f02060000000020009c0100" {
+		t.Errorf("unexpected encoding %x", list.Encode())
+	}
+	if !list.Remove("9c") || len(list) != 1 {
+		t.Errorf("failed to remove tag 9C")
+	}
+	if err := list.Set("9f", nil); err == nil {
+		t.Errorf("should throw an error on an incomplete tag")
+	}
+}
+
+func TestChipData(t *testing.T) {
+	isobyte, _ := hex.DecodeString("600009000002003020078020C0124500000000000000030000035900510001000800375304872000000848D2306226000000362000003737303030303333303030303038373730303030303333F9FF7FA34D1778A001575F2A020360820274008407A0000006021010950508000488009A032103039C01009F02060000000003009F03060000000000009F090201009F101C9F01A00000000088692C8C00000000000000000000000000000000009F1A0203609F1E0835313838343138349F26089839C8F4F17310739F2701809F3303E0F8C89F34030200009F3501229F360203A19F37046669A26B9F4104000003599F5301520011DF0108353138383431383400063430303032300000000000000000")
+
+	parsed := NewISOStruct("spec1987pos.yml", false)
+	if err := parsed.Unpack(isobyte); err != nil {
+		t.Fatalf("failed to unpack valid isomsg: %s", err.Error())
+	}
+	chip, err := parsed.ChipData(55)
+	if err != nil {
+		t.Fatalf("failed to decode field 55: %s", err.Error())
+	}
+	cryptogram, ok := chip.Get("9F26")
+	if !ok || hex.EncodeToString(cryptogram.Value) != "9839c8f4f1731073" {
+		t.Errorf("unexpected cryptogram %x", cryptogram.Value)
+	}
+
+	chip.Remove("9F53")
+	chip.Set("9F27", []byte{0x40})
+	packed, err := parsed.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack isomsg: %s", err.Error())
+	}
+
+	reparsed := NewISOStruct("spec1987pos.yml", false)
+	if err := reparsed.Unpack(packed); err != nil {
+		t.Fatalf("failed to unpack packed isomsg: %s", err.Error())
+	}
+	rechip, _ := reparsed.ChipData(55)
+	if _, ok := rechip.Get("9F53"); ok {
+		t.Errorf("tag 9F53 should have been removed")
+	}
+	cid, _ := rechip.Get("9F27")
+	if !bytes.Equal(cid.Value, []byte{0x40}) {
+		t.Errorf("expected tag 9F27 to be 40 found %x", cid.Value)
+	}
+	if value, _ := reparsed.GetField(58); value != "df01083531383834313834" {
+		t.Errorf("fields after 55 were not packed back correctly, field 58 is %s", value)
+	}
+}
+
+func TestChipDataRepacked(t *testing.T) {
+	// field 55 is 157 bytes long, padded with 00 to whole pairs of bytes
+	isobyte, _ := hex.DecodeString("600009000002003020078020C0124500000000000000030000035900510001000800375304872000000848D2306226000000362000003737303030303333303030303038373730303030303333F9FF7FA34D1778A001575F2A020360820274008407A0000006021010950508000488009A032103039C01009F02060000000003009F03060000000000009F090201009F101C9F01A00000000088692C8C00000000000000000000000000000000009F1A0203609F1E0835313838343138349F26089839C8F4F17310739F2701809F3303E0F8C89F34030200009F3501229F360203A19F37046669A26B9F4104000003599F5301520011DF0108353138383431383400063430303032300000000000000000")
+
+	parsed := NewISOStruct("spec1987pos.yml", false)
+	if err := parsed.Unpack(isobyte); err != nil {
+		t.Fatalf("failed to unpack valid isomsg: %s", err.Error())
+	}
+	if value, _ := parsed.GetField(55); len(value) != 157*2 {
+		t.Errorf("expected field 55 to hold 157 bytes found %d", len(value)/2)
+	}
+	packed, err := parsed.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack isomsg: %s", err.Error())
+	}
+	if !bytes.Equal(packed, isobyte) {
+		t.Errorf("expected the message to pack back as\n%x\nfound\n%x", isobyte, packed)
+	}
+}
+
+func TestChipDataReplaced(t *testing.T) {
+	isobyte, _ := hex.DecodeString("600009000002003020078020C0124500000000000000030000035900510001000800375304872000000848D2306226000000362000003737303030303333303030303038373730303030303333F9FF7FA34D1778A001575F2A020360820274008407A0000006021010950508000488009A032103039C01009F02060000000003009F03060000000000009F090201009F101C9F01A00000000088692C8C00000000000000000000000000000000009F1A0203609F1E0835313838343138349F26089839C8F4F17310739F2701809F3303E0F8C89F34030200009F3501229F360203A19F37046669A26B9F4104000003599F5301520011DF0108353138383431383400063430303032300000000000000000")
+
+	replaced := NewISOStruct("spec1987pos.yml", false)
+	replaced.Unpack(isobyte)
+	replaced.ChipData(55)
+	if err := replaced.AddField(55, "9c0101"); err != nil {
+		t.Fatalf("failed to replace field 55: %s", err.Error())
+	}
+	packed, err := replaced.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack isomsg: %s", err.Error())
+	}
+	reparsed := NewISOStruct("spec1987pos.yml", false)
+	reparsed.Unpack(packed)
+	if value, _ := reparsed.GetField(55); value != "9c0101" {
+		t.Errorf("expected field 55 to be 9c0101 found %s", value)
+	}
+
+	removed := NewISOStruct("spec1987pos.yml", false)
+	removed.Unpack(isobyte)
+	removed.ChipData(55)
+	removed.RemoveField(55)
+	packed, err = removed.Pack()
+	if err != nil {
+		t.Fatalf("failed to pack isomsg: %s", err.Error())
+	}
+	reparsed = NewISOStruct("spec1987pos.yml", false)
+	reparsed.Unpack(packed)
+	if _, ok := reparsed.GetField(55); ok {
+		t.Errorf("expected field 55 to stay removed")
+	}
+
+	// lists handed out keep being packed after a pack
+	kept := NewISOStruct("spec1987pos.yml", false)
+	kept.Unpack(isobyte)
+	chip, _ := kept.ChipData(55)
+	kept.Pack()
+	chip.Set("9F27", []byte{0x40})
+	packed, _ = kept.Pack()
+	reparsed = NewISOStruct("spec1987pos.yml", false)
+	reparsed.Unpack(packed)
+	rechip, _ := reparsed.ChipData(55)
+	if cid, _ := rechip.Get("9F27"); !bytes.Equal(cid.Value, []byte{0x40}) {
+		t.Errorf("expected tag 9F27 to be 40 found %x", cid.Value)
+	}
+}
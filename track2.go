@@ -0,0 +1,109 @@
+package iso8583
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Track2 holds the parts of the track 2 equivalent data found in field 35
+type Track2 struct {
+	PAN           string
+	Expiry        string // YYMM
+	ServiceCode   string
+	Discretionary string
+}
+
+// String rebuilds the track 2 data using the = separator
+func (t Track2) String() string {
+	return t.PAN + "=" + t.Expiry + t.ServiceCode + t.Discretionary
+}
+
+// ParseTrack2 splits track 2 data into its parts, both the = and
+// D separators are understood
+func ParseTrack2(data string) (Track2, error) {
+	var track Track2
+	separator := strings.IndexAny(data, "=Dd")
+	if separator < 0 {
+		return track, fmt.Errorf("track 2 data has no separator")
+	}
+	track.PAN = data[0:separator]
+	if len(track.PAN) < 1 || len(track.PAN) > 19 {
+		return track, fmt.Errorf("track 2 data has a PAN of length %d", len(track.PAN))
+	}
+
+	rest := data[separator+1:]
+	// a missing expiry or service code is marked by the separator being repeated
+	if strings.HasPrefix(rest, "=") {
+		rest = rest[1:]
+	} else {
+		if len(rest) < 4 {
+			return track, fmt.Errorf("track 2 data has no expiry date")
+		}
+		track.Expiry, rest = rest[0:4], rest[4:]
+	}
+	if strings.HasPrefix(rest, "=") {
+		rest = rest[1:]
+	} else {
+		if len(rest) < 3 {
+			return track, fmt.Errorf("track 2 data has no service code")
+		}
+		track.ServiceCode, rest = rest[0:3], rest[3:]
+	}
+	track.Discretionary = rest
+	return track, nil
+}
+
+// Track2 returns the parsed track 2 data found in field 35
+func (iso *IsoStruct) Track2() (Track2, error) {
+	data, ok := iso.Elements.elements[35]
+	if !ok {
+		return Track2{}, fmt.Errorf("field 35 is not present")
+	}
+	return ParseTrack2(data)
+}
+
+// isTrack2 reports whether a field carries track data (ContentType z)
+func isTrack2(fieldDescription FieldDescription) bool {
+	return fieldDescription.ContentType == "z"
+}
+
+// track2Padding returns the pad nibble and justification used when packing
+// track data as bcd, unless the spec says otherwise values are left
+// justified and padded with F
+func track2Padding(fieldDescription FieldDescription) (string, string) {
+	pad := "f"
+	if fieldDescription.BcdPad != "" {
+		pad = bcdPadNibble(fieldDescription)
+	}
+	justify := "left"
+	if fieldDescription.BcdJustify != "" {
+		justify = fieldDescription.BcdJustify
+	}
+	return pad, justify
+}
+
+// packTrack2 packs track data, bcd packed fields use the D nibble as
+// separator while text fields use =
+func packTrack2(fieldDescription FieldDescription, data string) (string, error) {
+	if !fieldDescription.HeaderHex {
+		data = strings.NewReplacer("D", "=", "d", "=").Replace(data)
+		return encodeText(fieldDescription.Encoding, data)
+	}
+
+	digits := strings.NewReplacer("=", "d", "D", "d").Replace(data)
+	for i := 0; i < len(digits); i++ {
+		if (digits[i] < '0' || digits[i] > '9') && digits[i] != 'd' {
+			return "", fmt.Errorf("%q can not be packed as track data, it contains %q", data, data[i])
+		}
+	}
+	pad, justify := track2Padding(fieldDescription)
+	return packNibbles(digits, pad, justify)
+}
+
+// unpackTrack2 turns the hex of bcd packed track data back into length
+// characters, the D separator is returned as =
+func unpackTrack2(fieldDescription FieldDescription, digits string, length int) string {
+	_, justify := track2Padding(fieldDescription)
+	digits = trimBCDPad(digits, length, justify)
+	return strings.NewReplacer("d", "=", "D", "=").Replace(digits)
+}
@@ -0,0 +1,89 @@
+package iso8583
+
+import (
+	"encoding/hex"
+	"testing"
+)
+
+func TestParseTrack2(t *testing.T) {
+	ts := []struct {
+		data     string
+		expected Track2
+	}{
+		{"4761739001010010=22122011758928889", Track2{"4761739001010010", "2212", "201", "1758928889"}},
+		{"5304872000000848D23062260000003620000", Track2{"5304872000000848", "2306", "226", "0000003620000"}},
+		{"4761739001010010==2011758928889", Track2{"4761739001010010", "", "201", "1758928889"}},
+	}
+	for _, v := range ts {
+		track, err := ParseTrack2(v.data)
+		if err != nil {
+			t.Errorf("failed to parse %s: %s", v.data, err.Error())
+			continue
+		}
+		if track != v.expected {
+			t.Errorf("Expected %#v but got %#v", v.expected, track)
+		}
+	}
+
+	_, err := ParseTrack2("4761739001010010")
+	if err == nil {
+		t.Errorf("should throw an error on track data without a separator")
+	}
+}
+
+func TestTrack2Field(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:  {ContentType: "n", LenType: "fixed", MaxLen: 4, HeaderHex: true},
+		1:  {ContentType: "b", LenType: "fixed", MaxLen: 8, HeaderHex: true},
+		35: {ContentType: "z", LenType: "llvar", MaxLen: 37, HeaderHex: true},
+	})
+	one := NewISOStructFromSpec(spec, false)
+	one.AddMTI("0200")
+	one.AddField(35, "4761739001010010D22122011758928889")
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack track 2: %s", err.Error())
+	}
+	expected := "34" + "4761739001010010d22122011758928889"
+	if hex.EncodeToString([]byte(packed[10:])) != expected {
+		t.Errorf("Expected %s but got %x", expected, packed[10:])
+	}
+
+	one.AddField(35, "4761739001010010=2212201175892888")
+	packed, _ = one.ToString()
+	expected = "33" + "4761739001010010d2212201175892888f"
+	if hex.EncodeToString([]byte(packed[10:])) != expected {
+		t.Errorf("Expected %s but got %x", expected, packed[10:])
+	}
+
+	parsed, err := one.Parse(packed)
+	if err != nil {
+		t.Fatalf("failed to parse track 2: %s", err.Error())
+	}
+	value, _ := parsed.GetField(35)
+	if value != "4761739001010010=2212201175892888" {
+		t.Errorf("Expected 4761739001010010=2212201175892888 but got %s", value)
+	}
+	track, err := parsed.Track2()
+	if err != nil || track.PAN != "4761739001010010" || track.Expiry != "2212" {
+		t.Errorf("unexpected track 2 %#v", track)
+	}
+}
+
+func TestTrack2FromPosSample(t *testing.T) {
+	isobyte, _ := hex.DecodeString("600009000002003020078020C0124500000000000000030000035900510001000800375304872000000848D2306226000000362000003737303030303333303030303038373730303030303333F9FF7FA34D1778A001575F2A020360820274008407A0000006021010950508000488009A032103039C01009F02060000000003009F03060000000000009F090201009F101C9F01A00000000088692C8C00000000000000000000000000000000009F1A0203609F1E0835313838343138349F26089839C8F4F17310739F2701809F3303E0F8C89F34030200009F3501229F360203A19F37046669A26B9F4104000003599F5301520011DF0108353138383431383400063430303032300000000000000000")
+
+	parsed := NewISOStruct("spec1987pos.yml", false)
+	if err := parsed.Unpack(isobyte); err != nil {
+		t.Fatalf("failed to unpack valid isomsg: %s", err.Error())
+	}
+	track, err := parsed.Track2()
+	if err != nil {
+		t.Fatalf("failed to read track 2: %s", err.Error())
+	}
+	expected := Track2{"5304872000000848", "2306", "226", "0000003620000"}
+	if track != expected {
+		t.Errorf("Expected %#v but got %#v", expected, track)
+	}
+}
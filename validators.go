@@ -4,13 +4,41 @@ import (
 	"errors"
 	"fmt"
 	"strconv"
+	"strings"
 )
 
-// ValidationError happens when validation fails
+// Rules a field value can break
+const (
+	RuleMTI     = "mti"
+	RuleContent = "content"
+	RuleLength  = "length"
+)
+
+// ValidationError happens when validation fails, Field is 0 for the mti
 type ValidationError struct {
+	Field int
+	Label string
+	Rule  string
+	Value string
+
 	message string
 }
 
+func (e ValidationError) Error() string {
+	return fmt.Sprintf("field %d: %s", e.Field, e.message)
+}
+
+// ValidationErrors lists every problem found validating a message
+type ValidationErrors []ValidationError
+
+func (e ValidationErrors) Error() string {
+	messages := make([]string, len(e))
+	for i, err := range e {
+		messages[i] = err.Error()
+	}
+	return strings.Join(messages, "; ")
+}
+
 // MtiValidator validates and iso8583 mti
 func MtiValidator(mti MtiType) (bool, error) {
 	mtiString := mti.mti
@@ -34,7 +62,7 @@ func MtiValidator(mti MtiType) (bool, error) {
 func FixedLengthIntegerValidator(field int, length int, data string) (bool, error) {
 	var verify bool
 	if length != len(data) {
-		return verify, fmt.Errorf("field %d: expected length %d found %d instead", field, length, len(data))
+		return verify, ValidationError{Field: field, Rule: RuleLength, Value: data, message: fmt.Sprintf("expected length %d found %d instead", length, len(data))}
 	}
 	return true, nil
 }
@@ -48,7 +76,7 @@ func VariableLengthIntegerValidator(field int, min int, max int, data string) (b
 	if verify == true {
 		return verify, nil
 	}
-	return verify, fmt.Errorf("field %d: expected max length %d and min length %d found %d", field, max, min, dataLen)
+	return verify, ValidationError{Field: field, Rule: RuleLength, Value: data, message: fmt.Sprintf("expected max length %d and min length %d found %d", max, min, dataLen)}
 }
 
 // VariableLengthAlphaNumericValidator checks variable length alphanum Fields
@@ -60,5 +88,145 @@ func VariableLengthAlphaNumericValidator(field int, min int, max int, data strin
 	if verify == true {
 		return verify, nil
 	}
-	return verify, fmt.Errorf("field %d: expected max length %d and min length %d", field, max, min)
+	return verify, ValidationError{Field: field, Rule: RuleLength, Value: data, message: fmt.Sprintf("expected max length %d and min length %d", max, min)}
+}
+
+// SignedAmountValidator checks that a signed amount field (x+n) starts
+// with C or D followed by length digits
+func SignedAmountValidator(field int, length int, data string) (bool, error) {
+	var verify bool
+	if len(data) != length+1 {
+		return verify, ValidationError{Field: field, Rule: RuleLength, Value: data, message: fmt.Sprintf("expected length %d found %d instead", length+1, len(data))}
+	}
+	if data[0] != 'C' && data[0] != 'D' {
+		return verify, ValidationError{Field: field, Rule: RuleContent, Value: data, message: fmt.Sprintf("expected sign C or D found %q", data[0])}
+	}
+	_, err := strconv.ParseUint(data[1:], 10, 64)
+	if err != nil {
+		return verify, ValidationError{Field: field, Rule: RuleContent, Value: data, message: "amount can only contain integers"}
+	}
+	return true, nil
+}
+
+// contentClass reports whether c belongs to one of the character
+// classes (a, n, s and p for space) making up a content type
+func contentClass(contentType string, c byte) bool {
+	alpha := (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+	numeric := c >= '0' && c <= '9'
+	control := c < 0x20 || c == 0x7f
+	for _, class := range contentType {
+		switch {
+		case class == 'a' && alpha,
+			class == 'n' && numeric,
+			class == 's' && !alpha && !numeric && !control,
+			class == 'p' && c == ' ':
+			return true
+		}
+	}
+	return false
+}
+
+// checkContent checks the characters of a value held for a field
+func checkContent(fieldDescription FieldDescription, data string) error {
+	switch {
+	case isBCD(fieldDescription):
+		return checkCharacters(data, "digits", func(c byte) bool { return c >= '0' && c <= '9' })
+	case isTrack2(fieldDescription):
+		return checkCharacters(data, "track 2 data", func(c byte) bool {
+			return (c >= '0' && c <= '9') || c == '=' || c == 'D' || c == 'd'
+		})
+	case holdsHex(fieldDescription):
+		return checkCharacters(data, "hex", func(c byte) bool {
+			return (c >= '0' && c <= '9') || (c >= 'a' && c <= 'f') || (c >= 'A' && c <= 'F')
+		})
+	case isSignedAmount(fieldDescription):
+		if len(data) < 2 || (data[0] != 'C' && data[0] != 'D') {
+			return fmt.Errorf("expected C or D followed by digits found %q", data)
+		}
+		return checkCharacters(data[1:], "digits", func(c byte) bool { return c >= '0' && c <= '9' })
+	case fieldDescription.ContentType == "b" || fieldDescription.ContentType == "" || len(fieldDescription.Subfields) > 0:
+		// binary data and fields made of subfields carry any bytes
+		return nil
+	}
+	// the padding of a fixed field need not belong to its content type
+	start, end := padBounds(fieldDescription, data)
+	for i := start; i < end; i++ {
+		if !contentClass(fieldDescription.ContentType, data[i]) {
+			return fmt.Errorf("expected %s found %q at position %d", fieldDescription.ContentType, data[i], i+1)
+		}
+	}
+	return nil
+}
+
+// padBounds returns where the value of a fixed field starts
+// and ends within the padding padField puts around it
+func padBounds(fieldDescription FieldDescription, data string) (int, int) {
+	if fieldDescription.LenType != "fixed" {
+		return 0, len(data)
+	}
+	pad, justify := fieldPadding(fieldDescription)
+	if pad == "" {
+		return 0, len(data)
+	}
+	if justify == "left" {
+		return 0, len(strings.TrimRight(data, pad))
+	}
+	return len(data) - len(strings.TrimLeft(data, pad)), len(data)
+}
+
+// checkCharacters finds the first character of data that is not allowed
+func checkCharacters(data string, expected string, allowed func(c byte) bool) error {
+	for i := 0; i < len(data); i++ {
+		if !allowed(data[i]) {
+			return fmt.Errorf("expected %s found %q at position %d", expected, data[i], i+1)
+		}
+	}
+	return nil
+}
+
+// checkLength checks a value against the MinLen and MaxLen of its field,
+// fixed fields may be short as they are padded when packed
+func checkLength(fieldDescription FieldDescription, data string) error {
+	if fieldDescription.LenType == "fixed" {
+		data = trimPadNibble(fieldDescription, data)
+		if fieldDescription.MaxLen > 0 && len(data) > fieldDescription.MaxLen {
+			return fmt.Errorf("value of length %d exceeds max length %d", len(data), fieldDescription.MaxLen)
+		}
+		return nil
+	}
+	length := valueLength(fieldDescription, data)
+	if fieldDescription.MaxLen > 0 && length > fieldDescription.MaxLen {
+		return fmt.Errorf("value of length %d exceeds max length %d", length, fieldDescription.MaxLen)
+	}
+	if length < fieldDescription.MinLen {
+		return fmt.Errorf("value of length %d is shorter than min length %d", length, fieldDescription.MinLen)
+	}
+	return nil
+}
+
+// validateValue checks a field value against the content type and lengths
+// the spec gives the field, then against the validators it names
+func (s Spec) validateValue(field int64, data string) *ValidationError {
+	fieldDescription := s.fields[int(field)]
+	rule := RuleContent
+	err := checkContent(fieldDescription, data)
+	if err == nil {
+		rule = RuleLength
+		err = checkLength(fieldDescription, data)
+	}
+	for _, name := range fieldDescription.Validators {
+		if err != nil {
+			break
+		}
+		rule = name
+		if validator, ok := s.validator(name); ok {
+			err = validator(data)
+		} else {
+			err = fmt.Errorf("%s is an unknown validator", name)
+		}
+	}
+	if err != nil {
+		return &ValidationError{Field: int(field), Label: fieldDescription.Label, Rule: rule, Value: data, message: err.Error()}
+	}
+	return nil
 }
@@ -1,6 +1,9 @@
 package iso8583
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestMtiValidator(t *testing.T) {
 	mti := MtiType{mti: "0200"}
@@ -37,3 +40,196 @@ func TestLengthIntegerFields(t *testing.T) {
 		t.Errorf("failed to validate a valid variable length integer field; %s", err.Error())
 	}
 }
+
+func TestSignedAmountValidator(t *testing.T) {
+	check, _ := SignedAmountValidator(28, 8, "C00001500")
+	if check != true {
+		t.Errorf("failed to validate a valid signed amount")
+	}
+	_, err := SignedAmountValidator(28, 8, "00001500")
+	if err == nil {
+		t.Errorf("failed to spot a missing sign")
+	}
+	_, err = SignedAmountValidator(28, 8, "X00001500")
+	if err == nil {
+		t.Errorf("failed to spot an invalid sign")
+	}
+	_, err = SignedAmountValidator(28, 8, "C0000150A")
+	if err == nil {
+		t.Errorf("failed to spot an invalid amount")
+	}
+}
+
+func TestContentValidation(t *testing.T) {
+	spec := buildSpec(t, map[int]FieldDescription{
+		0:  {ContentType: "n", LenType: "fixed", MaxLen: 4},
+		1:  {ContentType: "b", LenType: "fixed", MaxLen: 8},
+		2:  {ContentType: "n", LenType: "llvar", MinLen: 12, MaxLen: 19},
+		3:  {ContentType: "n", LenType: "fixed", MaxLen: 6},
+		28: {ContentType: "x+n", LenType: "fixed", MaxLen: 9},
+		35: {ContentType: "z", LenType: "llvar", MaxLen: 37, HeaderHex: true},
+		37: {ContentType: "anp", LenType: "fixed", MaxLen: 12},
+		43: {ContentType: "ans", LenType: "llvar", MaxLen: 40},
+		49: {ContentType: "a", LenType: "fixed", MaxLen: 3},
+		52: {ContentType: "b", LenType: "fixed", MaxLen: 16, HeaderHex: true},
+		55: {ContentType: "b", LenType: "lllvar", MaxLen: 4, HeaderHex: true, Contain: "string"},
+	})
+
+	tests := []struct {
+		field int64
+		data  string
+		valid bool
+	}{
+		{2, "4111111111111111", true},
+		{2, "41111111", false},
+		{2, "41111111111111111111", false},
+		{2, "4111x11111111111", false},
+		{3, "000010", true},
+		{3, "00001a", false},
+		{3, "0000100", false},
+		{28, "C00001500", true},
+		{28, "X00001500", false},
+		{28, "C0000150A", false},
+		{35, "4111111111111111=2512101", true},
+		{35, "4111111111111111D2512101", true},
+		{35, "4111111111111111^2512101", false},
+		{37, "ABC 123", true},
+		{37, "ABC-123", false},
+		{43, "ACME STORE, NAIROBI", true},
+		{43, "ACME\tSTORE", false},
+		{49, "KES", true},
+		{49, "404", false},
+		{52, "ef9e490f10e11f22", true},
+		{52, "ef9e490f10e11fzz", false},
+		{55, "01020304", true},
+		{55, "0102030405", false},
+	}
+	for _, test := range tests {
+		one := NewISOStructFromSpec(spec, false)
+		err := one.AddField(test.field, test.data)
+		if test.valid && err != nil {
+			t.Errorf("field %d: expected %q to be valid: %s", test.field, test.data, err.Error())
+		}
+		if !test.valid && err == nil {
+			t.Errorf("field %d: expected %q to be refused", test.field, test.data)
+		}
+		if !test.valid && one.Bitmap[test.field-1] != 0 {
+			t.Errorf("field %d: refused value should not be added", test.field)
+		}
+	}
+}
+
+func TestLazyValidation(t *testing.T) {
+	one := NewISOStruct("spec1987.yml", false)
+	one.LazyValidation = true
+	one.AddMTI("0200")
+	one.AddField(3, "00001X")
+	one.AddField(4, "000000001500")
+	one.AddField(11, "00000Z")
+	errs := one.Validate()
+	if errs == nil || errs.Error() != "field 3: expected n found 'X' at position 6; field 11: expected n found 'Z' at position 6" {
+		t.Errorf("expected fields 3 and 11 to fail validation found %v", errs)
+	}
+
+	packed, err := one.ToString()
+	if err != nil {
+		t.Fatalf("failed to pack: %s", err.Error())
+	}
+	parser := NewISOStruct("spec1987.yml", false)
+	_, err = parser.Parse(packed)
+	if err == nil {
+		t.Errorf("expected parse to refuse garbage in numeric fields")
+	}
+
+	parser.LazyValidation = true
+	parsed, err := parser.Parse(packed)
+	if err != nil {
+		t.Fatalf("expected lazy parse to succeed: %s", err.Error())
+	}
+	if !parsed.LazyValidation || parsed.Validate() == nil {
+		t.Errorf("expected the parsed message to keep validation for later")
+	}
+
+	parsed.AddField(3, "000010")
+	parsed.AddField(11, "000001")
+	if errs = parsed.Validate(); errs != nil {
+		t.Errorf("expected fixed message to be valid: %s", errs.Error())
+	}
+}
+
+func TestValidationErrors(t *testing.T) {
+	one := NewISOStruct("spec1987.yml", false)
+	one.LazyValidation = true
+	one.Mti = MtiType{mti: "02000"}
+	one.AddField(3, "00001X")
+	one.AddField(4, "0000000015001")
+	one.AddField(11, "000001")
+
+	expected := ValidationErrors{
+		{Field: 0, Label: "Message Type Indicator", Rule: RuleMTI, Value: "02000"},
+		{Field: 3, Label: "Processing code", Rule: RuleContent, Value: "00001X"},
+		{Field: 4, Label: "Amount, transaction", Rule: RuleLength, Value: "0000000015001"},
+	}
+	errs := one.Validate()
+	if len(errs) != len(expected) {
+		t.Fatalf("expected %d problems found %v", len(expected), errs)
+	}
+	for i, err := range errs {
+		if err.Field != expected[i].Field || err.Label != expected[i].Label || err.Rule != expected[i].Rule || err.Value != expected[i].Value {
+			t.Errorf("expected %+v found %+v", expected[i], err)
+		}
+	}
+
+	strict := NewISOStruct("spec1987.yml", false)
+	err := strict.AddField(3, "00001X")
+	if invalid, ok := err.(ValidationError); !ok || invalid.Field != 3 || invalid.Rule != RuleContent {
+		t.Errorf("expected AddField to give a ValidationError for field 3 found %v", err)
+	}
+
+	_, err = FixedLengthIntegerValidator(11, 6, "0001")
+	if invalid, ok := err.(ValidationError); !ok || invalid.Rule != RuleLength || invalid.Value != "0001" {
+		t.Errorf("expected a length ValidationError found %v", err)
+	}
+}
+
+func TestPaddedRoundTrip(t *testing.T) {
+	for _, name := range []string{"1987", "1987pos", "1987pos2", "1987pos3", "1993", "2003"} {
+		spec := Specs[name]
+		one := NewISOStructFromSpec(spec, true)
+		one.AddMTI("0210")
+		expected := map[int64]string{}
+		for _, field := range spec.Fields() {
+			fieldDescription, _ := spec.Field(field)
+			if field < 2 || field > 128 || field == 65 || fieldDescription.LenType != "fixed" || fieldDescription.HeaderHex ||
+				len(fieldDescription.Subfields) > 0 || !strings.ContainsAny(fieldDescription.ContentType, "as") {
+				continue
+			}
+			value := "A"
+			if !strings.Contains(fieldDescription.ContentType, "a") {
+				value = "*"
+			}
+			err := one.AddField(int64(field), value)
+			if err != nil {
+				t.Errorf("%s: field %d: failed to add %q: %s", name, field, value, err.Error())
+				continue
+			}
+			expected[int64(field)] = value + strings.Repeat(" ", fieldDescription.MaxLen-1)
+		}
+
+		packed, err := one.ToString()
+		if err != nil {
+			t.Errorf("%s: failed to pack: %s", name, err.Error())
+			continue
+		}
+		parsed, err := one.Parse(packed)
+		if err != nil {
+			t.Errorf("%s: failed to parse padded fields: %s", name, err.Error())
+			continue
+		}
+		for field, value := range expected {
+			if found, _ := parsed.GetField(field); found != value {
+				t.Errorf("%s: field %d: expected %q found %q", name, field, value, found)
+			}
+		}
+	}
+}